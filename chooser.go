@@ -0,0 +1,556 @@
+package termutil
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nsf/termbox-go"
+)
+
+// ChoiceKeymap holds the keys of ChoiceIndex and its variants, bound to the
+// names of its commands; see DefaultChoiceKeymap.
+var ChoiceKeymap = DefaultChoiceKeymap()
+
+// DefaultChoiceKeymap returns a new copy of ChoiceIndex's default keys.
+func DefaultChoiceKeymap() *Keymap {
+	return keymapFrom(map[string]string{
+		"C-v":            "next-page",
+		"next":           "next-page",
+		"M-v":            "previous-page",
+		"prior":          "previous-page",
+		"C-c":            "cancel",
+		"C-g":            "cancel",
+		"UP":             "previous-line",
+		"C-p":            "previous-line",
+		"DOWN":           "next-line",
+		"C-n":            "next-line",
+		"LEFT":           "scroll-left",
+		"C-b":            "scroll-left",
+		"RIGHT":          "scroll-right",
+		"C-f":            "scroll-right",
+		"C-a":            "beginning-of-line",
+		"Home":           "beginning-of-line",
+		"M-<":            "beginning-of-buffer",
+		"M->":            "end-of-buffer",
+		"RET":            "accept",
+		"DEL":            "delete-backward-char",
+		"C-h":            "delete-backward-char",
+		"C-u":            "kill-whole-line",
+		"mouse-1":        "mouse-select",
+		"double-mouse-1": "mouse-accept",
+		"wheel-up":       "previous-line",
+		"wheel-down":     "next-line",
+	})
+}
+
+// ChoiceMarkKeymap holds the keys ChoiceIndices adds to ChoiceKeymap for
+// marking choices; see DefaultChoiceMarkKeymap.
+var ChoiceMarkKeymap = DefaultChoiceMarkKeymap()
+
+// DefaultChoiceMarkKeymap returns a new copy of the default keys for marking
+// choices in ChoiceIndices.
+func DefaultChoiceMarkKeymap() *Keymap {
+	return keymapFrom(map[string]string{
+		"SPC": "toggle-mark",
+		"C-@": "toggle-mark",
+		"M-a": "mark-all",
+		"M-u": "unmark-all",
+		"M-t": "toggle-marks",
+	})
+}
+
+// Choice is a choice for ChoiceItems, with more to show than its label.
+type Choice struct {
+	// Label is what the filter matches, shown in Fg and Bg.
+	Label  string
+	Fg, Bg termbox.Attribute
+	// Annotation is shown at the right of the row, e.g. a size, date or key
+	// binding. It stays put when the label is scrolled or cut short.
+	Annotation string
+	// Description is shown dimmed on a line of its own under the label.
+	Description string
+	// Disabled choices are shown dimmed and skipped by the selection.
+	Disabled bool
+}
+
+// Wraps plain choices as Choice items.
+func choiceLabels(choices []string) []Choice {
+	ret := make([]Choice, len(choices))
+	for i, s := range choices {
+		ret[i].Label = s
+	}
+	return ret
+}
+
+// ChoiceOptions configures ChoiceWithOptions.
+type ChoiceOptions struct {
+	// Default is the index of the choice selected at first. It is also
+	// what is returned if the user cancels.
+	Default int
+	// Context, if non-nil, stops the chooser when it is done. The chooser
+	// then returns Default and the context's error.
+	Context context.Context
+	// Preview, if non-nil, splits the screen between the list and a
+	// preview of the selected choice, which Preview draws into r, e.g. the
+	// first lines of a file. It is called again only when the selection
+	// or the size of the preview changes.
+	Preview func(sel int, r *Region)
+	// Split is the fraction of the screen's width the list takes up when
+	// there is a preview, or of its height if PreviewBelow is set. Zero
+	// means half.
+	Split float64
+	// PreviewBelow puts the preview under the list instead of to its right.
+	PreviewBelow bool
+	// SingleColumn keeps the choices in one column even when they are
+	// short enough to be laid out in several.
+	SingleColumn bool
+}
+
+// ChoiceWithOptions is the chooser behind ChoiceIndex and ChoiceItems,
+// configured by opts. It returns the index of the choice made, or
+// opts.Default and ErrCancelled if the user pressed C-g or C-c.
+func ChoiceWithOptions(title string, items []Choice, opts ChoiceOptions) (int, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	c := newChooser(title, items, opts.Default, nil)
+	c.preview = opts.Preview
+	c.split = opts.Split
+	c.below = opts.PreviewBelow
+	c.single = opts.SingleColumn
+	ret, err := c.run(ctx, ChoiceKeymap)
+	if err != nil {
+		return opts.Default, err
+	}
+	return ret, nil
+}
+
+// ChoiceMatchAttr is how the characters of a choice matching the filter typed
+// into ChoiceIndex are highlighted.
+var ChoiceMatchAttr = termbox.AttrBold
+
+func choiceIndex(ctx context.Context, title string, choices []Choice, def int, f func(int, int, int)) (int, error) {
+	c := newChooser(title, choices, def, f)
+	ret, err := c.run(ctx, ChoiceKeymap)
+	if err != nil {
+		return def, err
+	}
+	return ret, nil
+}
+
+func choiceIndices(ctx context.Context, title string, choices []string, marked []int) ([]int, error) {
+	c := newChooser(title, choiceLabels(choices), 0, nil)
+	c.marks = make(map[int]bool)
+	for _, i := range marked {
+		if 0 <= i && i < len(choices) {
+			c.marks[i] = true
+		}
+	}
+	ret, err := c.run(ctx, overlayKeymap(ChoiceKeymap, ChoiceMarkKeymap))
+	if err != nil {
+		return marked, err
+	}
+	if len(c.marks) == 0 {
+		if len(choices) == 0 {
+			// There was nothing to choose.
+			return []int{}, nil
+		}
+		return []int{ret}, nil
+	}
+	indices := make([]int, 0, len(c.marks))
+	for i := range c.marks {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	return indices, nil
+}
+
+// Returns a copy of km with the bindings of extra added, for the variants of
+// ChoiceIndex with keys of their own.
+func overlayKeymap(km, extra *Keymap) *Keymap {
+	ret := km.Copy()
+	for seq, command := range extra.Bindings() {
+		ret.Bind(seq, command)
+	}
+	return ret
+}
+
+// Runs the chooser with the keys in km until the user chooses or cancels.
+func (c *chooser) run(ctx context.Context, km *Keymap) (int, error) {
+	for {
+		sx, sy := screen.Size()
+		c.draw(sx, sy)
+		ev, err := pollEvent(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if ev.Type != termbox.EventKey && ev.Type != termbox.EventMouse {
+			continue
+		}
+		cmd, keys, err := readKeySequence(ctx, km, ParseTermboxEvent(ev), nil)
+		if err == ErrCancelled {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		if ret, done := c.handleKey(cmd, strings.Join(keys, " "), ev); done {
+			if c.cancelled {
+				return 0, ErrCancelled
+			}
+			return ret, nil
+		}
+	}
+}
+
+// The state of ChoiceIndex. Typing filters the choices: the rows shown are
+// those matching the query, best first.
+type chooser struct {
+	title    string
+	choices  []Choice
+	callback func(int, int, int)
+	query    string
+	// Indices into choices of the rows shown, and the runes of each which
+	// match the query, by index into choices.
+	rows    []int
+	matches map[int][]int
+	// Index into rows of the selected row, and of the first row shown.
+	selection, offset int
+	// Number of runes scrolled off the left of every row.
+	cx int
+	// The indices into choices of the marked choices, when choosing several
+	// with ChoiceIndices; nil otherwise.
+	marks map[int]bool
+	// The size of the part of the screen the list is drawn in.
+	lw, lh int
+	// The number of columns the rows are laid out in and, if more than
+	// one, how wide each is; see gridColumns. single keeps it to one.
+	cols, cw int
+	single   bool
+	// The preview, if any, and how the screen is split with it; see
+	// ChoiceOptions.
+	preview func(int, *Region)
+	split   float64
+	below   bool
+	// The tree whose shown nodes are the choices, for ChoiceTree.
+	tree *tree
+	// What the preview last drew, and for which choice.
+	pane      *Region
+	paneSel   int
+	cancelled bool
+}
+
+func newChooser(title string, choices []Choice, def int, f func(int, int, int)) *chooser {
+	c := &chooser{title: title, choices: choices, callback: f}
+	c.filter()
+	if 0 <= def && def < len(choices) {
+		c.selectRow(def, 1)
+	}
+	return c
+}
+
+// Works out the rows matching the query and selects the best.
+func (c *chooser) filter() {
+	c.rows = c.rows[:0]
+	c.matches = make(map[int][]int)
+	scores := make(map[int]int)
+	for i, choice := range c.choices {
+		if score, pos, ok := FuzzyMatch(c.query, choice.Label); ok {
+			c.rows = append(c.rows, i)
+			c.matches[i] = pos
+			scores[i] = score
+		}
+	}
+	if c.tree != nil {
+		// Keep the tree's order, and the path to each match.
+		c.rows = c.tree.withAncestors(c.rows)
+	} else {
+		sort.SliceStable(c.rows, func(a, b int) bool {
+			return scores[c.rows[a]] > scores[c.rows[b]]
+		})
+	}
+	c.offset = 0
+	c.selectRow(0, 1)
+}
+
+// Selects row i, or if its choice is disabled the nearest enabled row,
+// looking first in the direction dir (1 or -1).
+func (c *chooser) selectRow(i, dir int) {
+	if i >= len(c.rows) {
+		i = len(c.rows) - 1
+	}
+	if i < 0 {
+		i = 0
+	}
+	c.selection = i
+	for _, d := range []int{dir, -dir} {
+		for j := i; 0 <= j && j < len(c.rows); j += d {
+			if !c.choices[c.rows[j]].Disabled {
+				c.selection = j
+				return
+			}
+		}
+	}
+}
+
+// The number of screen lines row i takes up.
+func (c *chooser) height(i int) int {
+	if c.choices[c.rows[i]].Description != "" {
+		return 2
+	}
+	return 1
+}
+
+// Returns the row shown at x, y on the screen, or -1 if there's none.
+func (c *chooser) rowAt(x, y int) int {
+	if c.cols > 1 {
+		return c.gridAt(x, y)
+	}
+	line := 1
+	for i := c.offset; i < len(c.rows) && line <= y; i++ {
+		line += c.height(i)
+		if y < line {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *chooser) draw(sx, sy int) {
+	screen.HideCursor()
+	screen.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	c.layout(sx, sy)
+	Printstring(c.title, 0, 0)
+	if c.query != "" {
+		qx := RunewidthStr(c.title) + 1
+		Printstring(c.query, qx, 0)
+		screen.SetCursor(qx+RunewidthStr(c.query), 0)
+	}
+	c.cols, c.cw = c.gridColumns()
+	if c.cols > 1 {
+		c.cx = 0
+		c.drawGrid()
+	} else {
+		c.drawList()
+	}
+	if len(c.rows) > 0 {
+		if c.callback != nil {
+			c.callback(c.rows[c.selection], sx, sy)
+		}
+	}
+	if c.preview != nil {
+		c.drawPreview(sx, sy)
+	}
+	screen.Flush()
+}
+
+// Draws the rows one to a line, scrolled to show the selection.
+func (c *chooser) drawList() {
+	lw, lh := c.lw, c.lh
+	nc := len(c.rows) - 1
+	for c.selection < c.offset {
+		c.offset -= 5
+		if c.offset < 0 {
+			c.offset = 0
+		}
+	}
+	for c.offset < c.selection && c.lines(c.offset, c.selection+1) > lh-1 {
+		c.offset += 5
+		if c.offset >= nc {
+			c.offset = nc
+		}
+		if c.offset > c.selection {
+			c.offset = c.selection
+		}
+	}
+	y := 1
+	for i := c.offset; i < len(c.rows) && y < lh; i++ {
+		c.drawRow(i, 3, y, lw)
+		if c.cx > 0 {
+			Printstring("←", 2, y)
+		}
+		if i == c.selection {
+			Printstring(">", 1, y)
+		}
+		y += c.height(i)
+	}
+}
+
+// Draws row i at x, y, with its checkbox if choices are being marked, up to
+// column end.
+func (c *chooser) drawRow(i, x, y, end int) {
+	idx := c.rows[i]
+	if c.marks != nil {
+		if c.marks[idx] {
+			Printstring("[x]", x, y)
+		} else {
+			Printstring("[ ]", x, y)
+		}
+		x += 4
+	}
+	c.drawChoice(idx, x, y, end)
+}
+
+// The number of screen lines rows from up to to take up.
+func (c *chooser) lines(from, to int) int {
+	ret := 0
+	for i := from; i < to; i++ {
+		ret += c.height(i)
+	}
+	return ret
+}
+
+// Draws choices[idx] at x, y, with its label scrolled by cx and highlighting
+// the runes which match the query, its annotation at the right of the list
+// (which is sx wide) and its description on the line below.
+func (c *chooser) drawChoice(idx, x, y, sx int) {
+	choice := c.choices[idx]
+	fg, bg := choice.Fg, choice.Bg
+	if choice.Disabled {
+		fg |= termbox.AttrDim
+	}
+	end := sx
+	if choice.Annotation != "" {
+		aw := RunewidthStr(choice.Annotation)
+		end = sx - aw - 1
+		if end < x {
+			end = x
+		}
+		PrintStringFgBg(sx-aw, y, choice.Annotation, fg, bg)
+	}
+	label, pos := choice.Label, c.matches[idx]
+	dx := 0
+	if c.tree != nil {
+		label, pos = c.tree.indent(idx, label, pos)
+		dx = RunewidthStr(label) - RunewidthStr(choice.Label)
+	}
+	c.drawClipped(label, x, y, end, fg, bg, pos)
+	if choice.Description != "" && y+1 < c.lh {
+		c.drawClipped(choice.Description, x+dx, y+1, sx, termbox.AttrDim, termbox.ColorDefault, nil)
+	}
+}
+
+// Draws s at x, y, scrolled by cx and cut short before column end, without
+// splitting a wide rune. The runes at positions pos are highlighted.
+func (c *chooser) drawClipped(s string, x, y, end int, fg, bg termbox.Attribute, pos []int) {
+	for i, r := range []rune(s) {
+		for len(pos) > 0 && pos[0] < i {
+			pos = pos[1:]
+		}
+		if i < c.cx {
+			continue
+		}
+		w := Runewidth(r)
+		if x+w > end {
+			return
+		}
+		rfg := fg
+		if len(pos) > 0 && pos[0] == i {
+			rfg |= ChoiceMatchAttr
+		}
+		PrintRuneBgFg(x, y, r, rfg, bg)
+		x += w
+	}
+}
+
+// Marks or unmarks choices[idx].
+func (c *chooser) setMark(idx int, on bool) {
+	if on {
+		c.marks[idx] = true
+	} else {
+		delete(c.marks, idx)
+	}
+}
+
+// Acts on a key sequence and the command it's bound to, if any. Returns the
+// index of the choice made and true if the key finished choosing.
+func (c *chooser) handleKey(cmd, key string, ev termbox.Event) (int, bool) {
+	switch cmd {
+	case "next-page":
+		c.selectRow(c.selection+(c.lh-5)*c.cols, 1)
+	case "previous-page":
+		c.selectRow(c.selection-(c.lh-5)*c.cols, -1)
+	case "cancel":
+		c.cancelled = true
+		return 0, true
+	case "previous-line":
+		c.selectRow(c.selection-c.cols, -1)
+	case "next-line":
+		c.selectRow(c.selection+c.cols, 1)
+	case "scroll-left":
+		if c.cols > 1 {
+			c.selectRow(c.selection-1, -1)
+		} else if c.cx > 0 {
+			c.cx--
+		}
+	case "scroll-right":
+		if c.cols > 1 {
+			c.selectRow(c.selection+1, 1)
+		} else {
+			c.cx++
+		}
+	case "beginning-of-line":
+		c.cx = 0
+	case "beginning-of-buffer":
+		c.selectRow(0, 1)
+	case "end-of-buffer":
+		c.selectRow(len(c.rows)-1, -1)
+	case "accept":
+		if len(c.rows) == 0 {
+			// Nothing matches the query.
+			return 0, len(c.choices) == 0
+		}
+		if c.choices[c.rows[c.selection]].Disabled {
+			// Every choice listed is disabled.
+			break
+		}
+		return c.rows[c.selection], true
+	case "mouse-select", "mouse-accept":
+		i := c.rowAt(ev.MouseX, ev.MouseY)
+		if i < 0 || ev.MouseX >= c.lw || ev.MouseY >= c.lh || c.choices[c.rows[i]].Disabled {
+			break
+		}
+		c.selection = i
+		if cmd == "mouse-accept" {
+			return c.rows[c.selection], true
+		}
+	case "toggle-mark":
+		if c.marks != nil && len(c.rows) > 0 && !c.choices[c.rows[c.selection]].Disabled {
+			c.setMark(c.rows[c.selection], !c.marks[c.rows[c.selection]])
+			c.selectRow(c.selection+1, 1)
+		}
+	case "mark-all", "unmark-all", "toggle-marks":
+		if c.marks != nil {
+			for _, idx := range c.rows {
+				if c.choices[idx].Disabled {
+					continue
+				}
+				c.setMark(idx, cmd == "mark-all" || (cmd == "toggle-marks" && !c.marks[idx]))
+			}
+		}
+	case "expand", "collapse", "toggle-expand":
+		if c.tree != nil && len(c.rows) > 0 {
+			c.treeCommand(cmd)
+		}
+	case "delete-backward-char":
+		if c.query != "" {
+			_, rs := utf8.DecodeLastRuneInString(c.query)
+			c.query = c.query[:len(c.query)-rs]
+			c.filter()
+		}
+	case "kill-whole-line":
+		c.query = ""
+		c.filter()
+	case "":
+		if utf8.RuneCountInString(key) == 1 && ev.Type == termbox.EventKey {
+			c.query += key
+			c.filter()
+		}
+	}
+	if c.selection < 0 {
+		c.selection = 0
+	}
+	return 0, false
+}
@@ -0,0 +1,187 @@
+package termutil
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChoiceIndices(t *testing.T) {
+	s := memScreen(t, 40, 10)
+	choices := []string{"apple", "banana", "cherry", "avocado"}
+	for _, tc := range []struct {
+		name   string
+		keys   []string
+		marked []int
+		want   []int
+	}{
+		{"unmarked", []string{"C-n", "RET"}, nil, []int{1}},
+		{"marking", []string{"SPC", "C-n", "SPC", "RET"}, nil, []int{0, 2}},
+		{"unmarking", []string{"C-n", "SPC", "RET"}, []int{1, 3}, []int{3}},
+		{"mark all", []string{"M-a", "RET"}, nil, []int{0, 1, 2, 3}},
+		{"mark all listed", []string{"a", "v", "M-a", "C-u", "RET"}, []int{1}, []int{1, 3}},
+		{"unmark all", []string{"M-u", "RET"}, []int{0, 2}, []int{0}},
+		{"invert", []string{"M-t", "RET"}, []int{0, 2}, []int{1, 3}},
+		{"out of range", []string{"RET"}, []int{-1, 2, 4}, []int{2}},
+	} {
+		if err := s.PushKeys(tc.keys...); err != nil {
+			t.Fatal(err)
+		}
+		if got := ChoiceIndices("Pick", choices, tc.marked); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestChoiceIndicesCancelled(t *testing.T) {
+	s := memScreen(t, 40, 10)
+	marked := []int{1}
+	s.PushKeys("M-a", "C-g")
+	got, err := ChoiceIndicesContext(context.Background(), "Pick", []string{"a", "b", "c"}, marked)
+	if err != ErrCancelled || !reflect.DeepEqual(got, marked) {
+		t.Errorf("got %v, %v; want %v, %v", got, err, marked, ErrCancelled)
+	}
+}
+
+func TestChoiceIndicesEmpty(t *testing.T) {
+	s := memScreen(t, 40, 10)
+	s.PushKeys("SPC", "M-a", "RET")
+	got := ChoiceIndices("Pick", nil, nil)
+	if got == nil || len(got) != 0 {
+		t.Errorf("got %#v, want an empty slice", got)
+	}
+}
+
+func TestChoiceIndexFiltered(t *testing.T) {
+	s := memScreen(t, 40, 10)
+	choices := []string{"xaxxb", "cherry", "ab"}
+	for _, tc := range []struct {
+		keys []string
+		want int
+	}{
+		// The best match is listed first, but its index is still 2.
+		{[]string{"a", "b", "RET"}, 2},
+		{[]string{"a", "b", "C-n", "RET"}, 0},
+		{[]string{"c", "RET"}, 1},
+		{[]string{"c", "DEL", "C-n", "RET"}, 1},
+	} {
+		s.PushKeys(tc.keys...)
+		if got := ChoiceIndex("Pick", choices, 0); got != tc.want {
+			t.Errorf("%v: got %d, want %d", tc.keys, got, tc.want)
+		}
+	}
+}
+
+func TestChoiceIndexMouse(t *testing.T) {
+	s := memScreen(t, 40, 10)
+	lastClick.at = time.Time{}
+	choices := []string{"a", "b", "c", "d", "e"}
+	for _, tc := range []struct {
+		name string
+		push func()
+		want int
+	}{
+		{"click", func() {
+			s.PushMouse("mouse-1", 5, 3)
+			s.PushKeys("RET")
+		}, 2},
+		{"double click", func() {
+			s.PushMouse("mouse-1", 5, 4)
+			s.PushMouse("mouse-1", 5, 4)
+		}, 3},
+		{"double click bound", func() {
+			s.PushMouse("double-mouse-1", 5, 1)
+		}, 0},
+		{"click below the rows", func() {
+			s.PushMouse("mouse-1", 5, 8)
+			s.PushKeys("RET")
+		}, 1},
+		{"click on the title", func() {
+			s.PushMouse("mouse-1", 5, 0)
+			s.PushKeys("RET")
+		}, 1},
+		{"wheel", func() {
+			s.PushMouse("wheel-down", 5, 1)
+			s.PushMouse("wheel-down", 5, 1)
+			s.PushMouse("wheel-up", 5, 1)
+			s.PushKeys("RET")
+		}, 2},
+		{"unbound buttons", func() {
+			s.PushMouse("mouse-3", 5, 3)
+			s.PushMouse("drag-mouse-1", 5, 3)
+			s.PushKeys("RET")
+		}, 1},
+	} {
+		tc.push()
+		if got := ChoiceIndex("Pick", choices, 1); got != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, got, tc.want)
+		}
+		// Don't let a click make a double click with the next case's.
+		lastClick.at = time.Time{}
+	}
+}
+
+func TestChoiceItemsDisabled(t *testing.T) {
+	s := memScreen(t, 40, 10)
+	lastClick.at = time.Time{}
+	items := []Choice{{Label: "a"}, {Label: "b", Disabled: true}, {Label: "c"}, {Label: "d", Disabled: true}}
+	for _, tc := range []struct {
+		name string
+		def  int
+		keys []string
+		want int
+	}{
+		{"skipped going down", 0, []string{"C-n", "RET"}, 2},
+		{"skipped going up", 2, []string{"C-p", "RET"}, 0},
+		{"not past the end", 2, []string{"C-n", "RET"}, 2},
+		{"default disabled", 1, []string{"RET"}, 2},
+		{"end of the list", 0, []string{"M->", "RET"}, 2},
+		{"filtered to a disabled one", 0, []string{"b", "RET", "DEL", "RET"}, 0},
+	} {
+		s.PushKeys(tc.keys...)
+		if got := ChoiceItems("Pick", items, tc.def); got != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	s.PushMouse("mouse-1", 5, 2)
+	s.PushMouse("mouse-1", 5, 2)
+	s.PushKeys("RET")
+	if got := ChoiceItems("Pick", items, 0); got != 0 {
+		t.Errorf("clicking a disabled choice: got %d, want 0", got)
+	}
+
+	s.PushKeys("RET", "C-n", "RET", "C-g")
+	got, err := ChoiceItemsContext(context.Background(), "Pick", []Choice{{Label: "x", Disabled: true}}, 0)
+	if got != 0 || err != ErrCancelled {
+		t.Errorf("all disabled: got %d, %v; want 0, %v", got, err, ErrCancelled)
+	}
+}
+
+func TestChoiceItemsDescriptions(t *testing.T) {
+	s := memScreen(t, 30, 6)
+	var items []Choice
+	for _, l := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		items = append(items, Choice{Label: l, Description: "about " + l})
+	}
+	s.PushKeys("C-n", "C-n", "C-n", "C-p", "C-p", "C-p", "RET")
+	s.RecordFrames(true)
+	if got := ChoiceItems("Pick", items, 0); got != 0 {
+		t.Errorf("got %d, want 0", got)
+	}
+	// Rows are two lines each, so only two and a half fit under the
+	// title, and the list scrolls to keep the selection in view.
+	frames := s.Frames()
+	for i, want := range []string{"a", "b", "c", "d", "c", "b", "a"} {
+		sel := "| > " + want + " "
+		desc := "|   about " + want + " "
+		if !strings.Contains(frames[i], sel) || !strings.Contains(frames[i], desc) {
+			t.Errorf("frame %d doesn't show %s selected with its description:\n%s", i, want, frames[i])
+		}
+	}
+	if strings.Contains(frames[0], "about c") {
+		t.Errorf("a description drawn past the bottom of the screen:\n%s", frames[0])
+	}
+}
@@ -0,0 +1,128 @@
+package termutil
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Completer supplies completions to the line editor when the user presses
+// TAB.
+type Completer interface {
+	// Complete is given the buffer and the byte offset of point in it. It
+	// returns the possible completions and the span buffer[start:end]
+	// which any of them would replace.
+	Complete(buffer string, pos int) (candidates []string, start, end int)
+}
+
+// CompleterFunc lets an ordinary function be used as a Completer.
+type CompleterFunc func(buffer string, pos int) ([]string, int, int)
+
+// Complete calls f(buffer, pos).
+func (f CompleterFunc) Complete(buffer string, pos int) ([]string, int, int) {
+	return f(buffer, pos)
+}
+
+// ListCompleter returns a Completer which completes everything before point
+// to any of choices which starts with it.
+func ListCompleter(choices []string) Completer {
+	return CompleterFunc(func(buffer string, pos int) ([]string, int, int) {
+		var ret []string
+		for _, choice := range choices {
+			if strings.HasPrefix(choice, buffer[:pos]) {
+				ret = append(ret, choice)
+			}
+		}
+		return ret, 0, pos
+	})
+}
+
+// PromptWithCompleter is Prompt with TAB completion. TAB completes as far as
+// all the candidates agree, and a second TAB lists them above the prompt.
+func PromptWithCompleter(prompt string, refresh func(int, int), completer Completer) string {
+	ret, _ := EditWithOptions(prompt, refresh, EditOptions{Completer: completer})
+	return ret
+}
+
+// Completes the buffer at point, or lists the candidates if the last key was
+// also TAB and there was nothing more to fill in.
+func (e *lineEditor) complete() {
+	candidates, start, end := e.opts.Completer.Complete(e.buffer, e.bufpos)
+	e.thisCmd = "complete"
+	if len(candidates) == 0 {
+		return
+	}
+	current := e.buffer[start:end]
+	ins := commonPrefix(candidates)
+	if len(candidates) == 1 || (ins != current && len(ins) >= len(current)) {
+		e.buffer = e.buffer[:start] + ins + e.buffer[end:]
+		e.setPoint(start + len(ins))
+		if len(candidates) == 1 {
+			e.completions = nil
+		}
+	} else if e.lastCmd == "complete" {
+		e.completions = candidates
+	}
+}
+
+// Returns the longest prefix shared by every string in ss.
+func commonPrefix(ss []string) string {
+	prefix := ss[0]
+	for _, s := range ss[1:] {
+		i := 0
+		for i < len(prefix) && i < len(s) && prefix[i] == s[i] {
+			i++
+		}
+		prefix = prefix[:i]
+	}
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
+
+// Blanks the rows used by the completion list last time it was drawn. This
+// is only needed if there's no refresh function to redraw the screen.
+func (e *lineEditor) clearCompletions(x, y int) {
+	for r := 1; r <= e.listRows; r++ {
+		ClearLine(x, y-1-r)
+	}
+	e.listRows = 0
+}
+
+// Lists the completions in columns above the minibuffer line, using at most
+// half the screen.
+func (e *lineEditor) drawCompletions(x, y int) {
+	colw := 0
+	for _, c := range e.completions {
+		if w := RunewidthStr(c) + 2; w > colw {
+			colw = w
+		}
+	}
+	cols := x / colw
+	if cols < 1 {
+		cols = 1
+	}
+	rows := (len(e.completions) + cols - 1) / cols
+	maxrows := (y - 1) / 2
+	if maxrows < 1 {
+		maxrows = 1
+	}
+	shown := e.completions
+	if rows > maxrows {
+		rows = maxrows
+		shown = shown[:(rows-1)*cols]
+	}
+	e.clearCompletions(x, y)
+	e.listRows = rows
+	top := y - 1 - rows
+	for r := 0; r < rows; r++ {
+		ClearLine(x, top+r)
+	}
+	for i, c := range shown {
+		Printstring(c, (i%cols)*colw, top+i/cols)
+	}
+	if len(shown) < len(e.completions) {
+		Printstring(fmt.Sprintf("[%d more]", len(e.completions)-len(shown)), 0, y-2)
+	}
+}
@@ -0,0 +1,87 @@
+package termutil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	s := memScreen(t, 40, 10)
+	fruit := ListCompleter([]string{"apple", "apricot", "banana", "日本", "日光"})
+	// Completes the word before point.
+	words := CompleterFunc(func(buffer string, pos int) ([]string, int, int) {
+		start := strings.LastIndexByte(buffer[:pos], ' ') + 1
+		ret, from, to := ListCompleter([]string{"foo", "foobar"}).Complete(buffer[start:], pos-start)
+		return ret, start + from, start + to
+	})
+	for _, tc := range []struct {
+		name      string
+		completer Completer
+		def       string
+		keys      []string
+		want      string
+	}{
+		{"common prefix", fruit, "", []string{"a", "TAB"}, "ap"},
+		{"one candidate", fruit, "", []string{"b", "TAB"}, "banana"},
+		{"none", fruit, "", []string{"x", "TAB"}, "x"},
+		{"nothing more to fill in", fruit, "", []string{"a", "p", "TAB", "TAB", "r", "TAB"}, "apricot"},
+		{"whole runes", fruit, "", []string{"日", "TAB", "本", "TAB"}, "日本"},
+		{"at point", words, "x  y", []string{"C-a", "C-f", "C-f", "f", "TAB", "TAB", "b", "TAB"}, "x foobar y"},
+	} {
+		got := editKeys(t, s, EditOptions{Default: tc.def, Completer: tc.completer}, tc.keys...)
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCompleteList(t *testing.T) {
+	s := memScreen(t, 40, 10)
+	opts := EditOptions{Completer: ListCompleter([]string{"apple", "apricot", "banana"})}
+	s.RecordFrames(true)
+	editKeys(t, s, opts, "a", "TAB", "TAB", "r")
+	frames := s.Frames()
+	// One frame per key, and one as the prompt opens.
+	if len(frames) != 5 {
+		t.Fatalf("got %d frames, want 5", len(frames))
+	}
+	list := "|apple    apricot                        |"
+	for i, shown := range []bool{false, false, false, true, false} {
+		if strings.Contains(frames[i], list) != shown {
+			t.Errorf("frame %d: list shown is %v, want %v:\n%s", i, !shown, shown, frames[i])
+		}
+	}
+
+	// A long list is cut short with a count of the rest.
+	var many []string
+	for _, c := range "abcdefghijklmnopqrstuvwxyz" {
+		many = append(many, "x"+strings.Repeat(string(c), 6))
+	}
+	opts.Completer = ListCompleter(many)
+	s.RecordFrames(true)
+	editKeys(t, s, opts, "x", "TAB", "TAB")
+	frames = s.Frames()
+	if !strings.Contains(frames[len(frames)-1], "[14 more]") {
+		t.Errorf("no count of the candidates left out:\n%s", frames[len(frames)-1])
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	for _, tc := range []struct {
+		ss   []string
+		want string
+	}{
+		{[]string{"abc"}, "abc"},
+		{[]string{"abc", "abd", "ab"}, "ab"},
+		{[]string{"abc", "xyz"}, ""},
+		{[]string{"日本", "日光"}, "日"},
+	} {
+		if got := commonPrefix(tc.ss); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.ss, got, tc.want)
+		}
+	}
+	if got, _, _ := ListCompleter([]string{"a", "b", "ab"}).Complete("a", 1); !reflect.DeepEqual(got, []string{"a", "ab"}) {
+		t.Errorf("ListCompleter: got %q", got)
+	}
+}
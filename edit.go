@@ -0,0 +1,553 @@
+package termutil
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nsf/termbox-go"
+)
+
+// EditOptions holds the optional parts of the line editor started by
+// EditWithOptions. The zero value gives the same editor as Prompt.
+type EditOptions struct {
+	// Default is the text the editor starts with. It is also what is
+	// returned if the user cancels.
+	Default string
+	// Callback, if non-nil, is called after every keystroke with the
+	// buffer and the key pressed. Whatever it returns replaces the buffer.
+	Callback func(buffer, key string) string
+	// History, if non-nil, can be browsed with UP/DOWN or M-p/M-n and
+	// searched incrementally with C-r and C-s. Anything the user enters
+	// is added to it.
+	History *History
+	// KillRing receives the text killed by C-k, C-w, C-u, M-d and M-DEL,
+	// and is where C-y and M-y yank from. If nil, DefaultKillRing is used.
+	KillRing *KillRing
+	// Completer, if non-nil, is used to complete the buffer when the user
+	// presses TAB.
+	Completer Completer
+	// Context, if non-nil, stops the editor when it is done. The editor
+	// then returns Default and the context's error.
+	Context context.Context
+	// Keymap, if non-nil, is used instead of EditKeymap.
+	Keymap *Keymap
+	// PasteNewlines says what to do with line breaks in text pasted in
+	// bracketed paste mode, which is inserted as one edit.
+	PasteNewlines NewlinePolicy
+}
+
+// EditKeymap holds the keys of the line editor behind Prompt and Edit, bound
+// to the names of its commands; see DefaultEditKeymap. Printable keys which
+// aren't bound insert themselves.
+var EditKeymap = DefaultEditKeymap()
+
+// DefaultEditKeymap returns a new copy of the line editor's default keys.
+func DefaultEditKeymap() *Keymap {
+	return keymapFrom(map[string]string{
+		"LEFT":       "backward-char",
+		"C-b":        "backward-char",
+		"RIGHT":      "forward-char",
+		"C-f":        "forward-char",
+		"C-a":        "beginning-of-line",
+		"Home":       "beginning-of-line",
+		"C-e":        "end-of-line",
+		"End":        "end-of-line",
+		"M-b":        "backward-word",
+		"M-f":        "forward-word",
+		"UP":         "previous-history",
+		"M-p":        "previous-history",
+		"DOWN":       "next-history",
+		"M-n":        "next-history",
+		"C-r":        "isearch-backward",
+		"C-s":        "isearch-forward",
+		"TAB":        "complete",
+		"C-c":        "cancel",
+		"C-g":        "cancel",
+		"RET":        "accept",
+		"C-d":        "delete-char",
+		"deletechar": "delete-char",
+		"DEL":        "delete-backward-char",
+		"C-h":        "delete-backward-char",
+		"C-@":        "set-mark",
+		"C-k":        "kill-line",
+		"C-w":        "kill-region",
+		"C-u":        "kill-whole-line",
+		"M-DEL":      "backward-kill-word",
+		"M-d":        "kill-word",
+		"C-y":        "yank",
+		"M-y":        "yank-pop",
+		"C-_":        "undo",
+		"C-M-_":      "redo",
+		"M-_":        "redo",
+		"mouse-1":    "mouse-set-point",
+	})
+}
+
+// EditWithOptions is the line editor behind Prompt and Edit, configured by
+// opts. It returns what the user entered, or opts.Default and ErrCancelled if
+// they pressed C-g or C-c.
+func EditWithOptions(prompt string, refresh func(int, int), opts EditOptions) (string, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	km := opts.Keymap
+	if km == nil {
+		km = EditKeymap
+	}
+	e := newLineEditor(prompt, opts)
+	for {
+		x, y := screen.Size()
+		if refresh != nil {
+			refresh(x, y)
+		} else {
+			e.clearCompletions(x, y)
+		}
+		if e.search != nil {
+			e.drawSearch(x, y)
+		} else {
+			e.draw(x, y)
+		}
+		ev, err := pollEvent(ctx)
+		if err != nil {
+			return opts.Default, err
+		}
+		if ev.Type != termbox.EventKey && ev.Type != termbox.EventMouse {
+			continue
+		}
+		if ev.Type == termbox.EventKey && ev.Ch == 0 && ev.Key == KeyPasteStart {
+			text, err := readPaste(ctx)
+			if err != nil {
+				return opts.Default, err
+			}
+			e.paste(text)
+			continue
+		}
+		key := ParseTermboxEvent(ev)
+		cmd, keys, err := readKeySequence(ctx, km, key, refresh)
+		if err == ErrCancelled {
+			// C-g after a prefix key abandons the sequence.
+			continue
+		} else if err != nil {
+			return opts.Default, err
+		}
+		if ev.Type == termbox.EventMouse && cmd == "" {
+			continue
+		}
+		e.event = ev
+		if ret, done := e.handleKey(cmd, strings.Join(keys, " "), x); done {
+			if e.cancelled {
+				return ret, ErrCancelled
+			}
+			return ret, nil
+		}
+	}
+}
+
+// PromptWithHistory is Prompt with an input history; see EditOptions.
+func PromptWithHistory(prompt string, refresh func(int, int), history *History) string {
+	ret, _ := EditWithOptions(prompt, refresh, EditOptions{History: history})
+	return ret
+}
+
+type lineEditor struct {
+	prompt string
+	opts   EditOptions
+	buffer string
+	// Byte offset of point in buffer.
+	bufpos int
+	// Screen column of point, counted from the end of the prompt.
+	cursor int
+	// Number of runes scrolled off the left of the screen.
+	offset int
+	// Index into the history of the entry being shown; equal to its length
+	// when the user is editing a new line, which is then kept in histsave.
+	histpos  int
+	histsave string
+	// Non-nil during an incremental history search.
+	search *historySearch
+	// Byte offset of the mark, or -1 if it isn't set.
+	mark int
+	// What the previous and current keys did, so that kills can be
+	// appended to and yanks replaced by M-y.
+	lastCmd, thisCmd string
+	// Where the last yank started, and how far back in the kill ring it
+	// came from.
+	yankStart, yankIndex int
+	// States to go back to with undo and forward to with redo, most recent
+	// last.
+	undos, redos []editState
+	// Candidates listed by a second TAB, or nil, and how many rows the
+	// list took up when last drawn.
+	completions []string
+	listRows    int
+	// Set when the user cancels.
+	cancelled bool
+	// The event which started the current key sequence, for commands which
+	// use the mouse position.
+	event termbox.Event
+}
+
+type editState struct {
+	buffer string
+	bufpos int
+}
+
+func newLineEditor(prompt string, opts EditOptions) *lineEditor {
+	e := &lineEditor{prompt: prompt, opts: opts, mark: -1}
+	if opts.Default != "" {
+		x, _ := screen.Size()
+		e.buffer = opts.Default
+		e.moveToEnd(x)
+	}
+	if opts.History != nil {
+		e.histpos = opts.History.Len()
+	}
+	return e
+}
+
+func (e *lineEditor) draw(x, y int) {
+	iw := RunewidthStr(e.prompt + ": ")
+	ClearLine(x, y-1)
+	for iw+e.cursor >= x {
+		e.offset++
+		e.cursor--
+	}
+	for iw+e.cursor < iw {
+		e.offset--
+		e.cursor++
+	}
+	t, _ := trimString(e.buffer, e.offset)
+	Printstring(e.prompt+": "+t, 0, y-1)
+	if e.completions != nil {
+		e.drawCompletions(x, y)
+	}
+	screen.SetCursor(iw+e.cursor, y-1)
+	screen.Flush()
+}
+
+// Puts point at the end of the buffer, scrolling so that it's visible on a
+// screen x cells wide.
+func (e *lineEditor) moveToEnd(x int) {
+	e.bufpos = len(e.buffer)
+	if RunewidthStr(e.buffer) > x {
+		e.cursor = x - 1
+		e.offset = len(e.buffer) + 1 - x
+	} else {
+		e.offset = 0
+		e.cursor = RunewidthStr(e.buffer)
+	}
+}
+
+// Moves point to byte offset pos in the buffer.
+func (e *lineEditor) setPoint(pos int) {
+	e.bufpos = pos
+	e.cursor = RunewidthStr(e.buffer[:pos])
+	e.offset = 0
+}
+
+// Replaces the buffer with s, putting point at the start.
+func (e *lineEditor) setBuffer(s string) {
+	e.buffer = s
+	e.bufpos = 0
+	e.cursor = 0
+	e.offset = 0
+}
+
+func (e *lineEditor) runCallback(key string) {
+	if e.opts.Callback != nil {
+		result := e.opts.Callback(e.buffer, key)
+		if result != e.buffer {
+			e.setBuffer(result)
+			e.mark = -1
+			e.thisCmd = ""
+		}
+	}
+}
+
+// Replaces the buffer with the history entry n steps away from the current
+// one; positive n moves towards newer entries.
+func (e *lineEditor) historyMove(n, x int) {
+	h := e.opts.History
+	if h == nil {
+		return
+	}
+	pos := e.histpos + n
+	if pos < 0 || pos > h.Len() || pos == e.histpos {
+		return
+	}
+	if e.histpos == h.Len() {
+		e.histsave = e.buffer
+	}
+	e.histpos = pos
+	if pos == h.Len() {
+		e.buffer = e.histsave
+	} else {
+		e.buffer = h.entries[pos]
+	}
+	e.moveToEnd(x)
+}
+
+// Acts on a key sequence and the command it's bound to, if any. Returns the
+// editor's result and true if the key finished editing.
+func (e *lineEditor) handleKey(cmd, key string, x int) (string, bool) {
+	if e.search != nil {
+		return e.searchKey(cmd, key, x)
+	}
+	e.thisCmd = ""
+	before := editState{e.buffer, e.bufpos}
+	defer func() {
+		e.recordUndo(before)
+		e.lastCmd = e.thisCmd
+	}()
+	if cmd != "complete" {
+		e.completions = nil
+	}
+	buflen := len(e.buffer)
+	switch cmd {
+	case "backward-char":
+		if e.bufpos > 0 {
+			r, rs := utf8.DecodeLastRuneInString(e.buffer[:e.bufpos])
+			e.bufpos -= rs
+			e.cursor -= Runewidth(r)
+		}
+	case "forward-char":
+		if e.bufpos < buflen {
+			r, rs := utf8.DecodeRuneInString(e.buffer[e.bufpos:])
+			e.bufpos += rs
+			e.cursor += Runewidth(r)
+		}
+	case "beginning-of-line":
+		e.bufpos = 0
+		e.cursor = 0
+		e.offset = 0
+	case "end-of-line":
+		e.moveToEnd(x)
+	case "previous-history":
+		e.historyMove(-1, x)
+	case "next-history":
+		e.historyMove(1, x)
+	case "isearch-backward":
+		e.startSearch(false)
+	case "isearch-forward":
+		e.startSearch(true)
+	case "complete":
+		if e.opts.Completer != nil {
+			e.complete()
+		}
+	case "cancel":
+		e.runCallback(key)
+		e.cancelled = true
+		return e.opts.Default, true
+	case "accept":
+		e.runCallback(key)
+		if e.opts.History != nil {
+			e.opts.History.Add(e.buffer)
+		}
+		return e.buffer, true
+	case "delete-char":
+		if e.bufpos < buflen {
+			r, rs := utf8.DecodeRuneInString(e.buffer[e.bufpos:])
+			e.bufpos += rs
+			e.cursor += Runewidth(r)
+		} else {
+			e.runCallback(key)
+			return "", false
+		}
+		fallthrough
+	case "delete-backward-char":
+		if buflen > 0 {
+			if e.bufpos == buflen {
+				r, rs := utf8.DecodeLastRuneInString(e.buffer)
+				e.buffer = e.buffer[0 : buflen-rs]
+				e.bufpos -= rs
+				e.cursor -= Runewidth(r)
+			} else if e.bufpos > 0 {
+				r, rs := utf8.DecodeLastRuneInString(e.buffer[:e.bufpos])
+				e.buffer = e.buffer[:e.bufpos-rs] + e.buffer[e.bufpos:]
+				e.bufpos -= rs
+				e.cursor -= Runewidth(r)
+			}
+		}
+	case "set-mark":
+		e.mark = e.bufpos
+	case "kill-line":
+		e.kill(e.bufpos, buflen, false)
+	case "kill-region":
+		if e.mark >= 0 {
+			if e.mark > buflen {
+				e.mark = buflen
+			}
+			e.kill(e.mark, e.bufpos, e.mark < e.bufpos)
+		}
+	case "kill-whole-line":
+		e.kill(0, buflen, true)
+	case "backward-kill-word":
+		if buflen > 0 && e.bufpos > 0 {
+			e.kill(backwordWordIndex(e.buffer, e.bufpos), e.bufpos, true)
+		}
+	case "kill-word":
+		if buflen > 0 && e.bufpos < buflen {
+			e.kill(e.bufpos, forwardWordIndex(e.buffer, e.bufpos), false)
+		}
+	case "yank":
+		e.yankIndex = 0
+		e.yank(e.killRing().Get(0))
+	case "undo":
+		e.undo()
+	case "redo":
+		e.redo()
+	case "yank-pop":
+		if e.lastCmd == "yank" && e.killRing().Len() > 0 {
+			e.buffer = e.buffer[:e.yankStart] + e.buffer[e.bufpos:]
+			e.bufpos = e.yankStart
+			e.yankIndex++
+			e.yank(e.killRing().Get(e.yankIndex))
+		}
+	case "mouse-set-point":
+		_, y := screen.Size()
+		if e.event.MouseY == y-1 {
+			e.pointAtColumn(e.event.MouseX - RunewidthStr(e.prompt+": "))
+		}
+	case "backward-word":
+		if buflen > 0 && e.bufpos > 0 {
+			e.bufpos = backwordWordIndex(e.buffer, e.bufpos)
+			e.cursor = RunewidthStr(e.buffer[:e.bufpos])
+		}
+	case "forward-word":
+		if buflen > 0 && e.bufpos < buflen {
+			e.bufpos = forwardWordIndex(e.buffer, e.bufpos)
+			e.cursor = RunewidthStr(e.buffer[:e.bufpos])
+		}
+	case "":
+		if utf8.RuneCountInString(key) == 1 {
+			r, _ := utf8.DecodeLastRuneInString(e.buffer)
+			e.buffer = e.buffer[:e.bufpos] + key + e.buffer[e.bufpos:]
+			e.bufpos += len(key)
+			e.cursor += Runewidth(r)
+			e.thisCmd = "insert"
+		}
+	}
+	e.runCallback(key)
+	return "", false
+}
+
+func (e *lineEditor) killRing() *KillRing {
+	if e.opts.KillRing != nil {
+		return e.opts.KillRing
+	}
+	return DefaultKillRing
+}
+
+// Deletes the text between from and to into the kill ring. If the previous
+// key was also a kill the text is added to that kill instead, before it if
+// backward is true.
+func (e *lineEditor) kill(from, to int, backward bool) {
+	if from > to {
+		from, to = to, from
+	}
+	text := e.buffer[from:to]
+	if e.lastCmd == "kill" {
+		e.killRing().appendKill(text, backward)
+	} else {
+		e.killRing().Kill(text)
+	}
+	e.buffer = e.buffer[:from] + e.buffer[to:]
+	if e.mark >= to {
+		e.mark -= to - from
+	} else if e.mark > from {
+		e.mark = from
+	}
+	e.setPoint(from)
+	e.thisCmd = "kill"
+}
+
+// Inserts text at point, leaving the mark at its start and point at its end.
+func (e *lineEditor) yank(text string) {
+	e.yankStart = e.bufpos
+	e.buffer = e.buffer[:e.bufpos] + text + e.buffer[e.bufpos:]
+	e.mark = e.bufpos
+	e.setPoint(e.bufpos + len(text))
+	e.thisCmd = "yank"
+}
+
+// Makes the state before a key undoable if the key changed the buffer. A run
+// of self-inserted characters is undone in one go.
+func (e *lineEditor) recordUndo(before editState) {
+	if e.thisCmd == "undo" || e.buffer == before.buffer {
+		return
+	}
+	if e.thisCmd == "insert" && e.lastCmd == "insert" {
+		return
+	}
+	e.undos = append(e.undos, before)
+	e.redos = nil
+}
+
+func (e *lineEditor) undo() {
+	e.thisCmd = "undo"
+	if len(e.undos) == 0 {
+		return
+	}
+	e.redos = append(e.redos, editState{e.buffer, e.bufpos})
+	e.restore(e.undos[len(e.undos)-1])
+	e.undos = e.undos[:len(e.undos)-1]
+}
+
+func (e *lineEditor) redo() {
+	e.thisCmd = "undo"
+	if len(e.redos) == 0 {
+		return
+	}
+	e.undos = append(e.undos, editState{e.buffer, e.bufpos})
+	e.restore(e.redos[len(e.redos)-1])
+	e.redos = e.redos[:len(e.redos)-1]
+}
+
+// Moves point to the character drawn col cells after the prompt.
+func (e *lineEditor) pointAtColumn(col int) {
+	pos := 0
+	for i := 0; i < e.offset && pos < len(e.buffer); i++ {
+		_, rs := utf8.DecodeRuneInString(e.buffer[pos:])
+		pos += rs
+	}
+	w := 0
+	for pos < len(e.buffer) {
+		r, rs := utf8.DecodeRuneInString(e.buffer[pos:])
+		if w+Runewidth(r) > col {
+			break
+		}
+		w += Runewidth(r)
+		pos += rs
+	}
+	e.bufpos = pos
+	e.cursor = w
+}
+
+func (e *lineEditor) restore(st editState) {
+	e.buffer = st.buffer
+	e.setPoint(st.bufpos)
+}
+
+func backwordWordIndex(buffer string, bufpos int) int {
+	r, rs := utf8.DecodeLastRuneInString(buffer[:bufpos])
+	ret := bufpos - rs
+	r, rs = utf8.DecodeLastRuneInString(buffer[:ret])
+	for ret > 0 && WordCharacter(r) {
+		ret -= rs
+		r, rs = utf8.DecodeLastRuneInString(buffer[:ret])
+	}
+	return ret
+}
+
+func forwardWordIndex(buffer string, bufpos int) int {
+	r, rs := utf8.DecodeRuneInString(buffer[bufpos:])
+	ret := bufpos + rs
+	r, rs = utf8.DecodeRuneInString(buffer[ret:])
+	for ret < len(buffer) && WordCharacter(r) {
+		ret += rs
+		r, rs = utf8.DecodeRuneInString(buffer[ret:])
+	}
+	return ret
+}
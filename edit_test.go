@@ -0,0 +1,64 @@
+package termutil
+
+import (
+	"testing"
+	"time"
+)
+
+// Pushes keys followed by RET and returns what the line editor made of them.
+func editKeys(t *testing.T, s *MemScreen, opts EditOptions, keys ...string) string {
+	t.Helper()
+	if err := s.PushKeys(append(keys, "RET")...); err != nil {
+		t.Fatal(err)
+	}
+	got, err := EditWithOptions("Edit", nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestUndo(t *testing.T) {
+	s := memScreen(t, 40, 5)
+	// C-_ is also what the terminal sends for C-/. Each X typed at the
+	// end shows where undo or redo left point.
+	for _, tc := range []struct {
+		name string
+		def  string
+		keys []string
+		want string
+	}{
+		{"inserts undone together", "", []string{"a", "b", "c", "C-_"}, ""},
+		{"moving starts a new group", "", []string{"a", "b", "C-b", "c", "C-_", "X"}, "aXb"},
+		{"deletes undone one by one", "abc", []string{"DEL", "DEL", "C-_", "X"}, "abX"},
+		{"nothing to undo", "abc", []string{"C-_", "C-_", "X"}, "abcX"},
+		{"C-k", "hello world", []string{"C-a", "M-f", "C-k", "C-_", "X"}, "helloX world"},
+		{"C-u", "abc def", []string{"C-b", "C-b", "C-u", "C-_", "X"}, "abc dXef"},
+		{"undo twice", "ab", []string{"C-a", "C-k", "c", "C-_", "C-_", "X"}, "Xab"},
+		{"redo", "", []string{"a", "b", "C-_", "M-_", "X"}, "abX"},
+		{"redo C-k", "hello world", []string{"C-a", "M-f", "C-k", "C-_", "C-e", "C-M-_", "X"}, "helloX"},
+		{"redo twice", "ab", []string{"C-a", "C-k", "c", "C-_", "C-_", "M-_", "M-_"}, "c"},
+		{"nothing to redo", "ab", []string{"M-_", "X"}, "abX"},
+		{"an edit clears redo", "", []string{"a", "b", "C-_", "c", "M-_"}, "c"},
+		{"undo after redo", "", []string{"a", "C-_", "M-_", "C-_"}, ""},
+	} {
+		if got := editKeys(t, s, EditOptions{Default: tc.def}, tc.keys...); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestEditClick(t *testing.T) {
+	s := memScreen(t, 40, 5)
+	lastClick.at = time.Time{}
+	// "Edit: " takes up the first six columns.
+	s.PushMouse("mouse-1", 8, 4)
+	s.PushKeys("X")
+	s.PushMouse("mouse-1", 30, 4)
+	s.PushKeys("Y")
+	s.PushMouse("mouse-1", 1, 2)
+	s.PushKeys("Z")
+	if got := editKeys(t, s, EditOptions{Default: "hello"}); got != "heXlloYZ" {
+		t.Errorf("got %q, want %q", got, "heXlloYZ")
+	}
+}
@@ -0,0 +1,272 @@
+package termutil
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// The event loop every widget waits on. Besides the screen's own events it
+// delivers events and functions posted by the application, which wake a
+// widget that's waiting by interrupting its poll.
+var loop struct {
+	sync.Mutex
+	queue []loopItem
+	// Whether a widget is polling the screen, and whether it has already
+	// been interrupted.
+	waiting, interrupted bool
+}
+
+// DoubleClickTime is the longest time between two clicks of a mouse button in
+// the same place for the second to be reported as a double click.
+var DoubleClickTime = 400 * time.Millisecond
+
+// The last click read from the screen, to spot double clicks.
+var lastClick struct {
+	key  termbox.Key
+	x, y int
+	at   time.Time
+}
+
+// Something posted to the loop: a function to run, or an event to deliver.
+type loopItem struct {
+	f  func()
+	ev termbox.Event
+	// Set for keys replayed from a macro, which aren't recorded again.
+	replayed bool
+}
+
+// Post arranges for f to be called on the goroutine of the widget waiting
+// for input, which then redraws itself; this is how to update the screen from
+// another goroutine while a widget is open. If no widget is waiting, f is
+// called when the next one starts to wait. Post is safe to call from any
+// goroutine.
+func Post(f func()) {
+	postItem(loopItem{f: f}, false)
+}
+
+// Refresh asks the widget waiting for input to redraw itself.
+func Refresh() {
+	Post(func() {})
+}
+
+// PostEvent delivers ev to the widget waiting for input, or the next one to
+// wait, ahead of any events from the screen. It is safe to call from any
+// goroutine.
+func PostEvent(ev termbox.Event) {
+	postItem(loopItem{ev: ev}, false)
+}
+
+// Every calls f through Post every d, until stop is called; e.g. to animate a
+// spinner while a prompt is open. Ticks are skipped while the last call is
+// still waiting to run, so calls don't pile up when no widget is waiting.
+func Every(d time.Duration, f func()) (stop func()) {
+	ticker := time.NewTicker(d)
+	done := make(chan struct{})
+	var pending int32
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if atomic.CompareAndSwapInt32(&pending, 0, 1) {
+					Post(func() {
+						atomic.StoreInt32(&pending, 0)
+						f()
+					})
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
+
+func postItem(item loopItem, front bool) {
+	loop.Lock()
+	defer loop.Unlock()
+	if front {
+		loop.queue = append([]loopItem{item}, loop.queue...)
+	} else {
+		loop.queue = append(loop.queue, item)
+	}
+	if loop.waiting && !loop.interrupted {
+		loop.interrupted = true
+		// Interrupt blocks until the poll takes it.
+		go screen.Interrupt()
+	}
+}
+
+// Takes the next item posted to the loop. Functions are run, and reported
+// as an EventInterrupt so the widget redraws.
+func takePosted() (termbox.Event, bool) {
+	loop.Lock()
+	if len(loop.queue) == 0 {
+		loop.Unlock()
+		return termbox.Event{}, false
+	}
+	item := loop.queue[0]
+	loop.queue = loop.queue[1:]
+	loop.Unlock()
+	if item.f != nil {
+		item.f()
+		return termbox.Event{Type: termbox.EventInterrupt}, true
+	}
+	if !item.replayed {
+		recordKey(item.ev)
+	}
+	return item.ev, true
+}
+
+// NextEvent waits for the next event as the widgets do: events from the
+// screen, events from PostEvent, and an EventInterrupt after running each
+// function passed to Post. Applications can use it in their own main loop so
+// that Post and Every work there too. It returns ctx.Err() if ctx is done
+// first.
+func NextEvent(ctx context.Context) (termbox.Event, error) {
+	from := recordedKeys()
+	defer appReadKeys(from)
+	return pollEvent(ctx)
+}
+
+// Waits for the next event for a widget, giving up with ctx.Err() if ctx is
+// done first.
+func pollEvent(ctx context.Context) (termbox.Event, error) {
+	s := screen
+	poll := screenPoll(s)
+	for {
+		if ev, ok := takePosted(); ok {
+			return ev, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return termbox.Event{}, err
+		}
+		if !startWaiting() {
+			continue
+		}
+		var ev termbox.Event
+		var err error
+		if ctx.Done() == nil {
+			ev = poll()
+		} else {
+			ev, err = waitOrInterrupt(ctx, s, poll)
+		}
+		stopWaiting()
+		if ev.Type == termbox.EventInterrupt {
+			if err != nil {
+				return termbox.Event{}, err
+			}
+			// Woken by Post; go round to pick up what was posted.
+			continue
+		}
+		if err != nil {
+			// A real event raced the interrupt; keep it for later.
+			postItem(loopItem{ev: ev}, true)
+			return termbox.Event{}, err
+		}
+		recordKey(ev)
+		return markDoubleClick(ev), nil
+	}
+}
+
+// Sets ModDouble on ev if it's the second click of a double click.
+func markDoubleClick(ev termbox.Event) termbox.Event {
+	if ev.Type != termbox.EventMouse || ev.Mod != 0 || !isMouseButton(ev.Key) {
+		return ev
+	}
+	loop.Lock()
+	defer loop.Unlock()
+	now := time.Now()
+	if lastClick.key == ev.Key && lastClick.x == ev.MouseX && lastClick.y == ev.MouseY && now.Sub(lastClick.at) <= DoubleClickTime {
+		ev.Mod |= ModDouble
+		lastClick.at = time.Time{}
+	} else {
+		lastClick.key, lastClick.x, lastClick.y, lastClick.at = ev.Key, ev.MouseX, ev.MouseY, now
+	}
+	return ev
+}
+
+// Marks a widget as polling, unless something has been posted in the
+// meantime, in which case it returns false.
+func startWaiting() bool {
+	loop.Lock()
+	defer loop.Unlock()
+	if len(loop.queue) > 0 {
+		return false
+	}
+	loop.waiting = true
+	loop.interrupted = false
+	return true
+}
+
+func stopWaiting() {
+	loop.Lock()
+	defer loop.Unlock()
+	loop.waiting = false
+}
+
+// Runs poll in the background and waits for either it or ctx. When ctx wins,
+// the screen is interrupted and ctx.Err() returned along with the event the
+// poll returned, which is EventInterrupt unless a real event arrived in the
+// meantime. A panic in poll is passed on to the caller.
+func waitOrInterrupt(ctx context.Context, s Screen, poll func() termbox.Event) (termbox.Event, error) {
+	type result struct {
+		ev     termbox.Event
+		panicv interface{}
+	}
+	ch := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				ch <- result{panicv: r}
+			}
+		}()
+		ch <- result{ev: poll()}
+	}()
+	var r result
+	var err error
+	select {
+	case r = <-ch:
+	case <-ctx.Done():
+		loop.Lock()
+		if !loop.interrupted {
+			loop.interrupted = true
+			// Interrupt blocks until a poll takes it, which might be
+			// the next one if ours has already returned, so don't
+			// wait for it.
+			go s.Interrupt()
+		}
+		loop.Unlock()
+		r = <-ch
+		err = ctx.Err()
+	}
+	if r.panicv != nil {
+		panic(r.panicv)
+	}
+	return r.ev, err
+}
+
+// Polls for a key event, refreshing on resizes and posted functions.
+func pollKeyEvent(ctx context.Context, refresh func(int, int)) (termbox.Event, error) {
+	for {
+		ret, err := pollEvent(ctx)
+		if err != nil {
+			return ret, err
+		} else if ret.Type == termbox.EventKey {
+			return ret, nil
+		} else if ret.Type == termbox.EventResize && refresh != nil {
+			refresh(ret.Width, ret.Height)
+		} else if ret.Type == termbox.EventInterrupt && refresh != nil {
+			refresh(screen.Size())
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package termutil
+
+import (
+	"testing"
+	"time"
+)
+
+// Returns the number of items posted to the loop and not yet taken.
+func queued() int {
+	loop.Lock()
+	defer loop.Unlock()
+	return len(loop.queue)
+}
+
+// Throws away anything left posted to the loop.
+func drainPosted() {
+	loop.Lock()
+	defer loop.Unlock()
+	loop.queue = nil
+}
+
+func TestEveryDoesNotPileUp(t *testing.T) {
+	s := NewMemScreen(20, 3)
+	SetScreen(s)
+	defer SetScreen(nil)
+	defer drainPosted()
+
+	calls := 0
+	stop := Every(time.Millisecond, func() { calls++ })
+	time.Sleep(50 * time.Millisecond)
+	if n := queued(); n != 1 {
+		t.Errorf("%d calls queued with no widget waiting, want 1", n)
+	}
+
+	// Once a widget is waiting the pending call runs, and later ticks
+	// post again.
+	s.SetBlocking(true)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		s.PushKeys("RET")
+	}()
+	Prompt("Wait", nil)
+	stop()
+	if calls < 2 {
+		t.Errorf("f called %d times while the prompt was open, want several", calls)
+	}
+}
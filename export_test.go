@@ -0,0 +1,5 @@
+package termutil
+
+// PauseForAnyKey lets the golden tests, which are in termutil_test so they
+// can use termutiltest, snapshot the "<More>" prompt.
+var PauseForAnyKey = pauseForAnyKey
@@ -0,0 +1,104 @@
+package termutil
+
+import (
+	"unicode"
+)
+
+// FuzzyMatch reports whether the characters of pattern appear in s in order,
+// not necessarily next to each other, as fzf and ido match. If they do, it
+// also returns a score, higher for better matches: ones where the characters
+// are consecutive or start words score higher than ones scattered through s.
+// positions holds the index in s, counted in runes, of each matched
+// character. Case is ignored unless pattern contains an upper-case letter.
+func FuzzyMatch(pattern, s string) (score int, positions []int, ok bool) {
+	pr, sr := []rune(pattern), []rune(s)
+	if len(pr) == 0 {
+		return 0, nil, true
+	}
+	fold := true
+	for _, r := range pr {
+		if unicode.IsUpper(r) {
+			fold = false
+			break
+		}
+	}
+	eq := func(p, r rune) bool {
+		if fold {
+			return p == unicode.ToLower(r)
+		}
+		return p == r
+	}
+	// Find where the first match ends, then work back from there to the
+	// latest start, which gives the tightest match ending there.
+	pi, end := 0, -1
+	for i, r := range sr {
+		if eq(pr[pi], r) {
+			pi++
+			if pi == len(pr) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	pi = len(pr) - 1
+	start := end
+	for ; start >= 0; start-- {
+		if eq(pr[pi], sr[start]) {
+			if pi == 0 {
+				break
+			}
+			pi--
+		}
+	}
+	positions = make([]int, 0, len(pr))
+	pi = 0
+	for i := start; i <= end && pi < len(pr); i++ {
+		if eq(pr[pi], sr[i]) {
+			positions = append(positions, i)
+			pi++
+		}
+	}
+	return fuzzyScore(sr, positions), positions, true
+}
+
+// Scores a match of the runes of s at positions.
+func fuzzyScore(sr []rune, positions []int) int {
+	score := -minInt(positions[0], 8)
+	prev := -1
+	for i, p := range positions {
+		score += 16
+		if p == 0 || wordStart(sr[p-1], sr[p]) {
+			score += 8
+			if i == 0 {
+				score += 8
+			}
+		}
+		if prev >= 0 {
+			if p == prev+1 {
+				score += 12
+			} else {
+				score -= minInt(p-prev-1, 8)
+			}
+		}
+		prev = p
+	}
+	return score
+}
+
+// Whether b starts a word, coming after a.
+func wordStart(a, b rune) bool {
+	if !unicode.IsLetter(a) && !unicode.IsDigit(a) {
+		return true
+	}
+	return unicode.IsLower(a) && unicode.IsUpper(b)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,53 @@
+package termutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, s string
+		positions  []int
+		ok         bool
+	}{
+		{"", "anything", nil, true},
+		{"abc", "aXbXc", []int{0, 2, 4}, true},
+		{"fb", "foo_bar", []int{0, 4}, true},
+		// The tightest match ending at the first place one can.
+		{"ab", "a_xab", []int{3, 4}, true},
+		{"日本", "にっぽん日x本", []int{4, 6}, true},
+		{"xyz", "abc", nil, false},
+		{"ba", "ab", nil, false},
+		// Smart case: lower case matches either, upper case only itself.
+		{"fb", "FooBar", []int{0, 3}, true},
+		{"FB", "FooBar", []int{0, 3}, true},
+		{"FB", "foobar", nil, false},
+		{"Fb", "FooBar", nil, false},
+		{"Fb", "Foobar", []int{0, 3}, true},
+	} {
+		_, pos, ok := FuzzyMatch(tc.pattern, tc.s)
+		if ok != tc.ok || !reflect.DeepEqual(pos, tc.positions) {
+			t.Errorf("FuzzyMatch(%q, %q): got %v, %v; want %v, %v", tc.pattern, tc.s, pos, ok, tc.positions, tc.ok)
+		}
+	}
+}
+
+func TestFuzzyMatchOrder(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, better, worse string
+	}{
+		{"ab", "ab__", "a__b"},
+		{"bar", "bar", "foobar"},
+		{"fb", "foo_bar", "foxxbar"},
+		{"fb", "fooBar", "foobar"},
+		{"fb", "fb", "xfb"},
+		{"abc", "abxc", "axbxc"},
+	} {
+		b, _, okb := FuzzyMatch(tc.pattern, tc.better)
+		w, _, okw := FuzzyMatch(tc.pattern, tc.worse)
+		if !okb || !okw || b <= w {
+			t.Errorf("%q: %q scored %d, %q scored %d", tc.pattern, tc.better, b, tc.worse, w)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package termutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+var colorNames = []string{"default", "black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+var attrNames = []struct {
+	attr termbox.Attribute
+	name string
+}{
+	{termbox.AttrBold, "bold"},
+	{termbox.AttrBlink, "blink"},
+	{termbox.AttrHidden, "hidden"},
+	{termbox.AttrDim, "dim"},
+	{termbox.AttrUnderline, "underline"},
+	{termbox.AttrCursive, "cursive"},
+	{termbox.AttrReverse, "reverse"},
+}
+
+// Names an attribute as its color followed by any flags, e.g. "red|reverse".
+func attrString(a termbox.Attribute) string {
+	var flags termbox.Attribute
+	for _, an := range attrNames {
+		flags |= an.attr
+	}
+	color := a &^ flags
+	var ret string
+	if int(color) < len(colorNames) {
+		ret = colorNames[color]
+	} else {
+		ret = fmt.Sprintf("color%d", color)
+	}
+	for _, an := range attrNames {
+		if a&an.attr != 0 {
+			ret += "|" + an.name
+		}
+	}
+	return ret
+}
+
+// Snapshot renders the screen as stable text: a header with the size and
+// cursor position, every row between bars, then one line for each run of
+// cells drawn with non-default attributes, such as the reversed "^C" that
+// PrintRuneBgFg uses for control characters. The termutiltest package
+// compares snapshots with golden files.
+func (s *MemScreen) Snapshot() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot()
+}
+
+func (s *MemScreen) snapshot() string {
+	var sb strings.Builder
+	if s.cursorX < 0 {
+		fmt.Fprintf(&sb, "size %dx%d, cursor hidden\n", s.width, s.height)
+	} else {
+		fmt.Fprintf(&sb, "size %dx%d, cursor %d,%d\n", s.width, s.height, s.cursorX, s.cursorY)
+	}
+	for y := 0; y < s.height; y++ {
+		line, cells := s.lineCells(y)
+		pad := s.width - cells
+		if pad < 0 {
+			pad = 0
+		}
+		sb.WriteString("|" + line + strings.Repeat(" ", pad) + "|\n")
+	}
+	sb.WriteString("attributes:\n")
+	for y := 0; y < s.height; y++ {
+		for x := 0; x < s.width; {
+			c := s.cell(x, y)
+			end := x + 1
+			for end < s.width && s.cell(end, y).Fg == c.Fg && s.cell(end, y).Bg == c.Bg {
+				end++
+			}
+			if c.Fg != termbox.ColorDefault || c.Bg != termbox.ColorDefault {
+				fmt.Fprintf(&sb, "  row %d cols %d-%d: fg=%s bg=%s\n", y, x, end-1, attrString(c.Fg), attrString(c.Bg))
+			}
+			x = end
+		}
+	}
+	return sb.String()
+}
+
+// RecordFrames turns on or off the recording of a Snapshot at every Flush,
+// so the intermediate states of a widget can be checked after it returns.
+// Turning recording on discards any frames already recorded.
+func (s *MemScreen) RecordFrames(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recording = on
+	if on {
+		s.frames = nil
+	}
+}
+
+// Frames returns the snapshots recorded since RecordFrames(true).
+func (s *MemScreen) Frames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.frames...)
+}
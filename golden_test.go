@@ -0,0 +1,107 @@
+package termutil_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	termutil "github.com/japanoise/termbox-util"
+	"github.com/japanoise/termbox-util/termutiltest"
+	"github.com/nsf/termbox-go"
+)
+
+// Installs a w by h MemScreen for the length of the test.
+func goldenScreen(t *testing.T, w, h int) *termutil.MemScreen {
+	t.Helper()
+	s := termutil.NewMemScreen(w, h)
+	termutil.SetScreen(s)
+	t.Cleanup(func() { termutil.SetScreen(nil) })
+	return s
+}
+
+func TestGoldenMore(t *testing.T) {
+	s := goldenScreen(t, 20, 4)
+	termutil.Printstring("first line", 0, 0)
+	termutil.Printstring("second line", 0, 1)
+	s.PushKeys("SPC")
+	s.RecordFrames(true)
+	termutil.PauseForAnyKey(2)
+	frames := s.Frames()
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+	termutiltest.CheckGolden(t, "more", frames[0])
+	termutiltest.CheckGolden(t, "more-cleared", frames[1])
+}
+
+func TestGoldenChoiceScrolled(t *testing.T) {
+	s := goldenScreen(t, 20, 5)
+	s.PushKeys("C-f", "C-f", "C-n")
+	s.RecordFrames(true)
+	s.PushKeys("RET")
+	got := termutil.ChoiceIndex("Pick", []string{"alpha", "be\x01ta", "日本語", "x"}, 0)
+	if got != 1 {
+		t.Errorf("got %d, want 1", got)
+	}
+	frames := s.Frames()
+	termutiltest.CheckGolden(t, "choice-scrolled", frames[len(frames)-1])
+}
+
+func TestGoldenPager(t *testing.T) {
+	s := goldenScreen(t, 30, 5)
+	s.PushKeys("C-n", "q")
+	s.RecordFrames(true)
+	termutil.DisplayScreenMessage("one\n\ttabbed\nthree", "four\nfive\nsix")
+	frames := s.Frames()
+	termutiltest.CheckGolden(t, "pager", frames[0])
+	termutiltest.CheckGolden(t, "pager-scrolled", frames[1])
+}
+
+func TestSnapshotPadding(t *testing.T) {
+	s := goldenScreen(t, 5, 3)
+	s.RecordFrames(true)
+	termutil.Printstring("abcd漢", 0, 0)
+	termutil.Printstring("漢字", 0, 1)
+	s.SetCell(0, 2, '\x01', 0, 0)
+	s.Flush()
+	got := s.Frames()[0]
+	want := "size 5x3, cursor hidden\n|abcd漢|\n|漢字 |\n|\x01    |\nattributes:\n"
+	if got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+	for i, line := range strings.Split(got, "\n")[1:4] {
+		if !strings.HasPrefix(line, "|") || !strings.HasSuffix(line, "|") {
+			t.Errorf("row %d not between bars: %q", i, line)
+		}
+	}
+}
+
+func TestGoldenChoiceItems(t *testing.T) {
+	s := goldenScreen(t, 30, 8)
+	items := []termutil.Choice{
+		{Label: "notes.txt", Annotation: "1.2K"},
+		{Label: "locked", Annotation: "-", Disabled: true},
+		{Label: "日本語のファイル名はとても長い", Annotation: "30K"},
+		{Label: "red", Fg: termbox.ColorRed, Description: "a colour"},
+	}
+	s.PushKeys("C-n", "RET")
+	s.RecordFrames(true)
+	if got := termutil.ChoiceItems("Open", items, 0); got != 2 {
+		t.Errorf("got %d, want 2", got)
+	}
+	termutiltest.CheckGolden(t, "choice-items", s.Frames()[1])
+}
+
+func TestGoldenChoiceGrid(t *testing.T) {
+	s := goldenScreen(t, 30, 5)
+	choices := make([]string, 17)
+	for i := range choices {
+		choices[i] = fmt.Sprintf("f%02d", i)
+	}
+	s.PushKeys("C-n", "C-n", "RIGHT", "RET")
+	s.RecordFrames(true)
+	if got := termutil.ChoiceIndex("Open", choices, 0); got != 9 {
+		t.Errorf("got %d, want 9", got)
+	}
+	termutiltest.CheckGolden(t, "choice-grid", s.Frames()[3])
+}
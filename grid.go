@@ -0,0 +1,85 @@
+package termutil
+
+// Works out how many columns to lay the rows out in, and how wide each is.
+// Short choices which would overflow a single column are laid out across the
+// list in as many columns as fit, as ls -x does; choices wider than half the
+// list, or with annotations or descriptions, get a line each, as they do when
+// there's no room for rows under the title.
+func (c *chooser) gridColumns() (int, int) {
+	if c.single || c.tree != nil || c.lh < 2 || len(c.choices) <= c.lh-1 {
+		return 1, 0
+	}
+	// Measure every choice rather than just the rows, so the layout
+	// doesn't shift as the query changes.
+	w := 0
+	for _, choice := range c.choices {
+		if choice.Annotation != "" || choice.Description != "" {
+			return 1, 0
+		}
+		if lw := RunewidthStr(choice.Label); lw > w {
+			w = lw
+		}
+	}
+	if c.marks != nil {
+		w += 4
+	}
+	if w > c.lw/2 {
+		return 1, 0
+	}
+	// Room for the selection marker before each column and a gap after.
+	cw := w + 4
+	cols := c.lw / cw
+	if cols < 2 {
+		return 1, 0
+	}
+	return cols, cw
+}
+
+// Draws the rows in columns, scrolled a line at a time to show the
+// selection.
+func (c *chooser) drawGrid() {
+	lines := c.lh - 1
+	if lines < 1 {
+		// gridColumns doesn't lay out a grid with no room for it, but
+		// make sure the scrolling below finishes anyway.
+		lines = 1
+	}
+	sel, off := c.selection/c.cols, c.offset/c.cols
+	for sel < off {
+		off -= 5
+		if off < 0 {
+			off = 0
+		}
+	}
+	for sel-off >= lines {
+		off += 5
+		if off > sel {
+			off = sel
+		}
+	}
+	c.offset = off * c.cols
+	for i := c.offset; i < len(c.rows); i++ {
+		y := 1 + (i-c.offset)/c.cols
+		if y >= c.lh {
+			break
+		}
+		x := (i % c.cols) * c.cw
+		c.drawRow(i, x+3, y, x+c.cw-1)
+		if i == c.selection {
+			Printstring(">", x+1, y)
+		}
+	}
+}
+
+// Returns the row shown in the column at x, y, or -1 if there's none.
+func (c *chooser) gridAt(x, y int) int {
+	col := x / c.cw
+	if y < 1 || col >= c.cols {
+		return -1
+	}
+	i := c.offset + (y-1)*c.cols + col
+	if i >= len(c.rows) {
+		return -1
+	}
+	return i
+}
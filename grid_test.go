@@ -0,0 +1,107 @@
+package termutil
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns n choices named c00, c01...
+func gridChoices(n int) []string {
+	ret := make([]string, n)
+	for i := range ret {
+		ret[i] = fmt.Sprintf("c%02d", i)
+	}
+	return ret
+}
+
+func TestGridColumns(t *testing.T) {
+	long := append(gridChoices(19), strings.Repeat("x", 21))
+	annotated := choiceLabels(gridChoices(20))
+	annotated[3].Annotation = "1K"
+	for _, tc := range []struct {
+		name    string
+		choices []Choice
+		lw, lh  int
+		marks   bool
+		single  bool
+		cols    int
+		cw      int
+	}{
+		{"fits in a column", choiceLabels(gridChoices(9)), 40, 10, false, false, 1, 0},
+		{"overflows", choiceLabels(gridChoices(10)), 40, 10, false, false, 5, 7},
+		{"with checkboxes", choiceLabels(gridChoices(20)), 40, 10, true, false, 3, 11},
+		{"too wide", choiceLabels(long), 40, 10, false, false, 1, 0},
+		{"narrow screen", choiceLabels(gridChoices(20)), 13, 10, false, false, 1, 0},
+		{"just two columns", choiceLabels(gridChoices(20)), 14, 10, false, false, 2, 7},
+		{"annotated", annotated, 40, 10, false, false, 1, 0},
+		{"single column", choiceLabels(gridChoices(20)), 40, 10, false, true, 1, 0},
+		{"one line", choiceLabels(gridChoices(20)), 40, 2, false, false, 5, 7},
+		{"no lines", choiceLabels(gridChoices(20)), 40, 1, false, false, 1, 0},
+	} {
+		c := &chooser{choices: tc.choices, lw: tc.lw, lh: tc.lh, single: tc.single}
+		if tc.marks {
+			c.marks = map[int]bool{}
+		}
+		if cols, cw := c.gridColumns(); cols != tc.cols || cw != tc.cw {
+			t.Errorf("%s: got %d columns %d wide, want %d %d wide", tc.name, cols, cw, tc.cols, tc.cw)
+		}
+	}
+}
+
+func TestGridKeys(t *testing.T) {
+	s := memScreen(t, 40, 5)
+	lastClick.at = time.Time{}
+	// 40 choices in five columns of seven cells, four lines at a time.
+	choices := gridChoices(40)
+	for _, tc := range []struct {
+		name string
+		keys []string
+		want int
+	}{
+		{"RIGHT", []string{"RIGHT", "RIGHT", "RET"}, 2},
+		{"LEFT", []string{"RIGHT", "LEFT", "LEFT", "RET"}, 0},
+		{"across lines", []string{"C-n", "LEFT", "RET"}, 4},
+		{"down", []string{"C-n", "C-n", "RET"}, 10},
+		{"up", []string{"C-n", "C-n", "C-p", "RET"}, 5},
+		{"scrolled", []string{"C-n", "C-n", "C-n", "C-n", "C-n", "RIGHT", "RET"}, 26},
+		{"last", []string{"M->", "RET"}, 39},
+	} {
+		s.PushKeys(tc.keys...)
+		if got := ChoiceIndex("Pick", choices, 0); got != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	// The third column of the second line.
+	s.PushMouse("mouse-1", 16, 2)
+	s.PushKeys("RET")
+	if got := ChoiceIndex("Pick", choices, 0); got != 7 {
+		t.Errorf("click: got %d, want 7", got)
+	}
+	// Scrolling to the fifth line puts it at the top.
+	s.PushKeys("C-n", "C-n", "C-n", "C-n")
+	s.PushMouse("mouse-1", 9, 1)
+	s.PushKeys("RET")
+	if got := ChoiceIndex("Pick", choices, 0); got != 21 {
+		t.Errorf("click after scrolling: got %d, want 21", got)
+	}
+}
+
+func TestGridTinyScreen(t *testing.T) {
+	for _, h := range []int{1, 2} {
+		s := memScreen(t, 40, h)
+		s.PushKeys("C-n", "C-n", "RIGHT", "RET")
+		done := make(chan int)
+		go func() { done <- ChoiceIndex("Pick", gridChoices(30), 0) }()
+		select {
+		case got := <-done:
+			if h == 2 && got != 11 {
+				t.Errorf("%d lines: got %d, want 11", h, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("ChoiceIndex on a screen %d lines high didn't return", h)
+		}
+	}
+}
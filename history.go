@@ -0,0 +1,107 @@
+package termutil
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// History is a list of previous inputs to a prompt, oldest first. Entering a
+// line which is already in the history moves it to the end rather than
+// storing it twice. A History can be shared between prompts.
+type History struct {
+	entries []string
+	max     int
+	// The last incremental search, repeated by C-r or C-s with no query.
+	lastSearch string
+}
+
+// NewHistory returns an empty History holding at most max entries; older
+// entries are dropped as new ones are added. A max of 0 or less means no
+// limit.
+func NewHistory(max int) *History {
+	return &History{max: max}
+}
+
+// NewHistoryFrom returns an unlimited History holding entries, oldest first,
+// for prompts which search a list the caller keeps itself.
+func NewHistoryFrom(entries []string) *History {
+	h := NewHistory(0)
+	for _, entry := range entries {
+		h.Add(entry)
+	}
+	return h
+}
+
+// Add appends s to the history, removing any earlier copy of it. Empty
+// strings are ignored.
+func (h *History) Add(s string) {
+	if s == "" {
+		return
+	}
+	for i, entry := range h.entries {
+		if entry == s {
+			h.entries = append(h.entries[:i], h.entries[i+1:]...)
+			break
+		}
+	}
+	h.entries = append(h.entries, s)
+	if h.max > 0 && len(h.entries) > h.max {
+		h.entries = h.entries[len(h.entries)-h.max:]
+	}
+}
+
+// Entries returns a copy of the history, oldest first.
+func (h *History) Entries() []string {
+	return append([]string(nil), h.entries...)
+}
+
+// Len returns the number of entries in the history.
+func (h *History) Len() int {
+	return len(h.entries)
+}
+
+// Clear removes every entry from the history.
+func (h *History) Clear() {
+	h.entries = nil
+}
+
+var historyEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+var historyUnescaper = strings.NewReplacer("\\\\", "\\", "\\n", "\n")
+
+// Save writes the history to a plain text file, one entry per line, oldest
+// first. Backslashes and newlines within entries are escaped as \\ and \n.
+func (h *History) Save(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, entry := range h.entries {
+		w.WriteString(historyEscaper.Replace(entry))
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load adds the entries in a file written by Save to the history, as though
+// they had been entered in order. A file which doesn't exist is treated as
+// empty, so Load can be called unconditionally at startup.
+func (h *History) Load(filename string) error {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		h.Add(historyUnescaper.Replace(scanner.Text()))
+	}
+	return scanner.Err()
+}
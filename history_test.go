@@ -0,0 +1,81 @@
+package termutil
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHistoryAdd(t *testing.T) {
+	h := NewHistory(3)
+	for _, s := range []string{"a", "b", "", "a", "c", "d"} {
+		h.Add(s)
+	}
+	if got, want := h.Entries(), []string{"a", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	h.Add("c")
+	if got, want := h.Entries(), []string{"a", "d", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after re-adding c: got %q, want %q", got, want)
+	}
+	h.Clear()
+	if h.Len() != 0 {
+		t.Errorf("%d entries after Clear", h.Len())
+	}
+}
+
+func TestHistorySaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	entries := []string{
+		"plain",
+		`C:\path\to\file`,
+		"two\nlines",
+		`literal \n, not a newline`,
+		`trailing\`,
+		"日本語",
+	}
+	h := NewHistoryFrom(entries)
+	if err := h.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := "plain\nC:\\\\path\\\\to\\\\file\ntwo\\nlines\nliteral \\\\n, not a newline\ntrailing\\\\\n日本語\n"
+	if string(data) != file {
+		t.Errorf("file holds %q, want %q", data, file)
+	}
+
+	loaded := NewHistory(0)
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Entries(); !reflect.DeepEqual(got, entries) {
+		t.Errorf("loaded %q, want %q", got, entries)
+	}
+
+	// Loading adds to what's there, de-duplicating and trimming to max.
+	small := NewHistory(3)
+	small.Add("日本語")
+	small.Add("newer")
+	if err := small.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`literal \n, not a newline`, `trailing\`, "日本語"}
+	if got := small.Entries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestHistoryLoadMissing(t *testing.T) {
+	h := NewHistory(0)
+	h.Add("kept")
+	if err := h.Load(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("got %v for a missing file", err)
+	}
+	if got := h.Entries(); !reflect.DeepEqual(got, []string{"kept"}) {
+		t.Errorf("got %q", got)
+	}
+}
@@ -3,12 +3,18 @@
 package termutil
 
 import (
+	"context"
 	"errors"
+	"time"
 	"unicode/utf8"
 
 	"github.com/nsf/termbox-go"
 )
 
+// ErrCancelled is the error returned when the user cancels a widget with C-g
+// or C-c, e.g. by PromptCancel, EditCancel and YesNoCancel.
+var ErrCancelled = errors.New("User cancelled")
+
 //Get a string from the user. They can use typical emacs-ish editing commands,
 //or press C-c or C-g to cancel.
 func Prompt(prompt string, refresh func(int, int)) string {
@@ -42,317 +48,152 @@ func Edit(defval, prompt string, refresh func(int, int)) string {
 // function, and callback. It allows the user to edit the default
 // value. It returns what the user entered.
 func EditDynamicWithCallback(defval, prompt string, refresh func(int, int), callback func(string, string) string) string {
-	var buffer string
-	var bufpos, cursor, offset int
-	if defval == "" {
-		buffer = ""
-		bufpos = 0
-		cursor = 0
-		offset = 0
-	} else {
-		x, _ := termbox.Size()
-		buffer = defval
-		bufpos = len(buffer)
-		if RunewidthStr(buffer) > x {
-			cursor = x - 1
-			offset = len(buffer) + 1 - x
-		} else {
-			offset = 0
-			cursor = RunewidthStr(buffer)
-		}
-	}
-	iw := RunewidthStr(prompt + ": ")
-	for {
-		buflen := len(buffer)
-		x, y := termbox.Size()
-		if refresh != nil {
-			refresh(x, y)
-		}
-		ClearLine(x, y-1)
-		for iw+cursor >= x {
-			offset++
-			cursor--
-		}
-		for iw+cursor < iw {
-			offset--
-			cursor++
-		}
-		t, _ := trimString(buffer, offset)
-		Printstring(prompt+": "+t, 0, y-1)
-		termbox.SetCursor(iw+cursor, y-1)
-		termbox.Flush()
-		ev := termbox.PollEvent()
-		if ev.Type != termbox.EventKey {
-			continue
-		}
-		key := ParseTermboxEvent(ev)
-		switch key {
-		case "LEFT", "C-b":
-			if bufpos > 0 {
-				r, rs := utf8.DecodeLastRuneInString(buffer[:bufpos])
-				bufpos -= rs
-				cursor -= Runewidth(r)
-			}
-		case "RIGHT", "C-f":
-			if bufpos < buflen {
-				r, rs := utf8.DecodeRuneInString(buffer[bufpos:])
-				bufpos += rs
-				cursor += Runewidth(r)
-			}
-		case "C-a":
-			fallthrough
-		case "Home":
-			bufpos = 0
-			cursor = 0
-			offset = 0
-		case "C-e":
-			fallthrough
-		case "End":
-			bufpos = buflen
-			if RunewidthStr(buffer) > x {
-				cursor = x - 1
-				offset = buflen + 1 - x
-			} else {
-				offset = 0
-				cursor = RunewidthStr(buffer)
-			}
-		case "C-c":
-			fallthrough
-		case "C-g":
-			if callback != nil {
-				result := callback(buffer, key)
-				if result != buffer {
-					offset = 0
-					buffer, buflen, bufpos, cursor = recalcBuffer(result)
-				}
-			}
-			return defval
-		case "RET":
-			if callback != nil {
-				result := callback(buffer, key)
-				if result != buffer {
-					offset = 0
-					buffer, buflen, bufpos, cursor = recalcBuffer(result)
-				}
-			}
-			return buffer
-		case "C-d":
-			fallthrough
-		case "deletechar":
-			if bufpos < buflen {
-				r, rs := utf8.DecodeRuneInString(buffer[bufpos:])
-				bufpos += rs
-				cursor += Runewidth(r)
-			} else {
-				if callback != nil {
-					result := callback(buffer, key)
-					if result != buffer {
-						offset = 0
-						buffer, buflen, bufpos, cursor = recalcBuffer(result)
-					}
-				}
-				continue
-			}
-			fallthrough
-		case "DEL", "C-h":
-			if buflen > 0 {
-				if bufpos == buflen {
-					r, rs := utf8.DecodeLastRuneInString(buffer)
-					buffer = buffer[0 : buflen-rs]
-					bufpos -= rs
-					cursor -= Runewidth(r)
-				} else if bufpos > 0 {
-					r, rs := utf8.DecodeLastRuneInString(buffer[:bufpos])
-					buffer = buffer[:bufpos-rs] + buffer[bufpos:]
-					bufpos -= rs
-					cursor -= Runewidth(r)
-				}
-			}
-		case "C-u":
-			buffer = ""
-			buflen = 0
-			bufpos = 0
-			cursor = 0
-			offset = 0
-		case "M-DEL":
-			if buflen > 0 && bufpos > 0 {
-				delto := backwordWordIndex(buffer, bufpos)
-				buffer = buffer[:delto] + buffer[bufpos:]
-				buflen = len(buffer)
-				bufpos = delto
-				cursor = RunewidthStr(buffer[:bufpos])
-			}
-		case "M-d":
-			if buflen > 0 && bufpos < buflen {
-				delto := forwardWordIndex(buffer, bufpos)
-				buffer = buffer[:bufpos] + buffer[delto:]
-				buflen = len(buffer)
-			}
-		case "M-b":
-			if buflen > 0 && bufpos > 0 {
-				bufpos = backwordWordIndex(buffer, bufpos)
-				cursor = RunewidthStr(buffer[:bufpos])
-			}
-		case "M-f":
-			if buflen > 0 && bufpos < buflen {
-				bufpos = forwardWordIndex(buffer, bufpos)
-				cursor = RunewidthStr(buffer[:bufpos])
-			}
-		default:
-			if utf8.RuneCountInString(key) == 1 {
-				r, _ := utf8.DecodeLastRuneInString(buffer)
-				buffer = buffer[:bufpos] + key + buffer[bufpos:]
-				bufpos += len(key)
-				cursor += Runewidth(r)
-			}
-		}
-		if callback != nil {
-			result := callback(buffer, key)
-			if result != buffer {
-				offset = 0
-				buffer, buflen, bufpos, cursor = recalcBuffer(result)
-			}
-		}
-	}
+	ret, _ := EditWithOptions(prompt, refresh, EditOptions{Default: defval, Callback: callback})
+	return ret
 }
 
-func recalcBuffer(result string) (string, int, int, int) {
-	rlen := len(result)
-	return result, rlen, 0, 0
+// PromptCancel is Prompt, but returns ErrCancelled if the user presses C-g
+// or C-c, so that cancelling can be told apart from entering nothing.
+func PromptCancel(prompt string, refresh func(int, int)) (string, error) {
+	return EditWithOptions(prompt, refresh, EditOptions{})
 }
 
-func backwordWordIndex(buffer string, bufpos int) int {
-	r, rs := utf8.DecodeLastRuneInString(buffer[:bufpos])
-	ret := bufpos - rs
-	r, rs = utf8.DecodeLastRuneInString(buffer[:ret])
-	for ret > 0 && WordCharacter(r) {
-		ret -= rs
-		r, rs = utf8.DecodeLastRuneInString(buffer[:ret])
-	}
-	return ret
+// EditCancel is Edit, but returns ErrCancelled along with defval if the user
+// presses C-g or C-c, so that cancelling can be told apart from accepting the
+// default.
+func EditCancel(defval, prompt string, refresh func(int, int)) (string, error) {
+	return EditWithOptions(prompt, refresh, EditOptions{Default: defval})
 }
 
-func forwardWordIndex(buffer string, bufpos int) int {
-	r, rs := utf8.DecodeRuneInString(buffer[bufpos:])
-	ret := bufpos + rs
-	r, rs = utf8.DecodeRuneInString(buffer[ret:])
-	for ret < len(buffer) && WordCharacter(r) {
-		ret += rs
-		r, rs = utf8.DecodeRuneInString(buffer[ret:])
-	}
-	return ret
+// PromptContext is PromptCancel, but also gives up with ctx.Err() if ctx is
+// done before the user finishes.
+func PromptContext(ctx context.Context, prompt string, refresh func(int, int)) (string, error) {
+	return EditWithOptions(prompt, refresh, EditOptions{Context: ctx})
 }
 
 //Allows the user to select one of many choices displayed on-screen.
 //Takes a title, choices, and default selection. Returns an index into the choices
-//array; or def (default)
+//array; or def (default). Typing filters the choices with FuzzyMatch.
 func ChoiceIndex(title string, choices []string, def int) int {
 	return ChoiceIndexCallback(title, choices, def, nil)
 }
 
 //As ChoiceIndex, but calls a function after drawing the interface,
 //passing it the current selected choice, screen width, and screen height.
+//It isn't called while nothing matches the filter.
 func ChoiceIndexCallback(title string, choices []string, def int, f func(int, int, int)) int {
-	selection := def
-	nc := len(choices) - 1
-	if selection < 0 || selection > nc {
-		selection = 0
-	}
-	offset := 0
-	cx := 0
-	for {
-		sx, sy := termbox.Size()
-		termbox.HideCursor()
-		termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
-		Printstring(title, 0, 0)
-		for selection < offset {
-			offset -= 5
-			if offset < 0 {
-				offset = 0
-			}
-		}
-		for selection-offset >= sy-1 {
-			offset += 5
-			if offset >= nc {
-				offset = nc
-			}
-		}
-		for i, s := range choices[offset:] {
-			ts, _ := trimString(s, cx)
-			Printstring(ts, 3, i+1)
-			if cx > 0 {
-				Printstring("←", 2, i+1)
-			}
-		}
-		Printstring(">", 1, (selection+1)-offset)
-		if f != nil {
-			f(selection, sx, sy)
-		}
-		termbox.Flush()
-		ev := termbox.PollEvent()
-		if ev.Type != termbox.EventKey {
-			continue
-		}
-		key := ParseTermboxEvent(ev)
-		switch key {
-		case "C-v":
-			fallthrough
-		case "next":
-			selection += sy - 5
-			if selection >= len(choices) {
-				selection = len(choices) - 1
-			}
-		case "M-v":
-			fallthrough
-		case "prior":
-			selection -= sy - 5
-			if selection < 0 {
-				selection = 0
-			}
-		case "C-c":
-			fallthrough
-		case "C-g":
-			return def
-		case "UP", "C-p":
-			if selection > 0 {
-				selection--
-			}
-		case "DOWN", "C-n":
-			if selection < len(choices)-1 {
-				selection++
-			}
-		case "LEFT", "C-b":
-			if cx > 0 {
-				cx--
-			}
-		case "RIGHT", "C-f":
-			cx++
-		case "C-a", "Home":
-			cx = 0
-		case "M-<":
-			selection = 0
-		case "M->":
-			selection = len(choices) - 1
-		case "RET":
-			return selection
-		}
-	}
+	ret, _ := choiceIndex(context.Background(), title, choiceLabels(choices), def, f)
+	return ret
+}
+
+// ChoiceIndexContext is ChoiceIndex, but returns def along with ErrCancelled
+// if the user presses C-g or C-c, or with ctx.Err() if ctx is done first.
+func ChoiceIndexContext(ctx context.Context, title string, choices []string, def int) (int, error) {
+	return choiceIndex(ctx, title, choiceLabels(choices), def, nil)
+}
+
+// ChoiceItems is ChoiceIndex for choices with annotations, descriptions,
+// colors or disabled entries, as described by Choice. def is moved to the
+// nearest enabled choice if it is disabled.
+func ChoiceItems(title string, items []Choice, def int) int {
+	ret, _ := choiceIndex(context.Background(), title, items, def, nil)
+	return ret
+}
+
+// ChoiceItemsContext is ChoiceItems, but returns def along with ErrCancelled
+// if the user presses C-g or C-c, or with ctx.Err() if ctx is done first.
+func ChoiceItemsContext(ctx context.Context, title string, items []Choice, def int) (int, error) {
+	return choiceIndex(ctx, title, items, def, nil)
+}
+
+// ChoiceIndices is ChoiceIndex for choosing several choices at once. SPC or
+// C-@ marks or unmarks the current choice, M-a marks every choice listed, M-u
+// unmarks them and M-t toggles them; see ChoiceMarkKeymap. RET returns the
+// indices of the marked choices in order, or of the current choice if none
+// are marked, or an empty slice if there are no choices. The choices in marked
+// start off marked, and are returned if the user cancels.
+func ChoiceIndices(title string, choices []string, marked []int) []int {
+	ret, _ := choiceIndices(context.Background(), title, choices, marked)
+	return ret
+}
+
+// ChoiceIndicesContext is ChoiceIndices, but returns marked along with
+// ErrCancelled if the user presses C-g or C-c, or with ctx.Err() if ctx is
+// done first.
+func ChoiceIndicesContext(ctx context.Context, title string, choices []string, marked []int) ([]int, error) {
+	return choiceIndices(ctx, title, choices, marked)
+}
+
+// ChoiceTree lets the user choose a node of a tree, such as a directory or
+// an outline, with ChoiceIndex's keys. RIGHT expands the selected node, or
+// moves to its first child if it's expanded already; LEFT collapses it, or
+// moves to its parent; TAB toggles it (see ChoiceTreeKeymap). Nodes marked
+// HasChildren get their children by calling load with their path when first
+// expanded. Typing filters the nodes shown, keeping the parents of those
+// which match. It returns the path to the node chosen, as the index of each
+// node along the way among its siblings, or nil if the user cancels.
+func ChoiceTree(title string, roots []TreeNode, load func(path []int) []TreeNode) []int {
+	ret, _ := choiceTree(context.Background(), title, roots, load)
+	return ret
+}
+
+// ChoiceTreeContext is ChoiceTree, but returns ErrCancelled if the user
+// presses C-g or C-c, or ctx.Err() if ctx is done first.
+func ChoiceTreeContext(ctx context.Context, title string, roots []TreeNode, load func(path []int) []TreeNode) ([]int, error) {
+	return choiceTree(ctx, title, roots, load)
 }
 
 //Displays the prompt p and asks the user to say y or n. Returns true if y; false
 //if no.
 func YesNo(p string, refresh func(int, int)) bool {
-	ret, _ := yesNoChoice(p, false, refresh)
+	ret, _ := yesNoChoice(context.Background(), p, false, refresh)
 	return ret
 }
 
 //Same as YesNo, but will return a non-nil error if the user presses C-g.
 func YesNoCancel(p string, refresh func(int, int)) (bool, error) {
-	return yesNoChoice(p, true, refresh)
+	return yesNoChoice(context.Background(), p, true, refresh)
+}
+
+// YesNoContext is YesNoCancel, but also gives up with ctx.Err() if ctx is
+// done before the user answers.
+func YesNoContext(ctx context.Context, p string, refresh func(int, int)) (bool, error) {
+	return yesNoChoice(ctx, p, true, refresh)
+}
+
+// YesNoTimeout is YesNo, but answers def for the user if they haven't
+// answered within timeout.
+func YesNoTimeout(p string, refresh func(int, int), timeout time.Duration, def bool) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ret, err := yesNoChoice(ctx, p, false, refresh)
+	if err != nil {
+		return def
+	}
+	return ret
 }
 
 // Asks the user to press one of a set of keys. Returns the one which they pressed.
 func PressKey(p string, refresh func(int, int), keys ...string) string {
+	ret, _ := PressKeyContext(context.Background(), p, refresh, keys...)
+	return ret
+}
+
+// PressKeyTimeout is PressKey, but returns def if the user hasn't pressed
+// one of the keys within timeout.
+func PressKeyTimeout(p string, refresh func(int, int), timeout time.Duration, def string, keys ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ret, err := PressKeyContext(ctx, p, refresh, keys...)
+	if err != nil {
+		return def
+	}
+	return ret
+}
+
+// PressKeyContext is PressKey, but gives up with ctx.Err() if ctx is done
+// before the user presses one of the keys.
+func PressKeyContext(ctx context.Context, p string, refresh func(int, int), keys ...string) (string, error) {
 	var plen int
 	pm := p + " ("
 	for i, key := range keys {
@@ -363,48 +204,54 @@ func PressKey(p string, refresh func(int, int), keys ...string) string {
 	}
 	pm += ")"
 	plen = utf8.RuneCountInString(pm) + 1
-	x, y := termbox.Size()
+	x, y := screen.Size()
 	if refresh != nil {
 		refresh(x, y)
 	}
 	ClearLine(x, y-1)
 	Printstring(pm, 0, y-1)
-	termbox.SetCursor(plen, y-1)
-	termbox.Flush()
+	screen.SetCursor(plen, y-1)
+	screen.Flush()
 	for {
-		ev := termbox.PollEvent()
-		if ev.Type == termbox.EventResize {
-			x, y = termbox.Size()
+		ev, err := pollEvent(ctx)
+		if err != nil {
+			return "", err
+		}
+		if ev.Type == termbox.EventResize || ev.Type == termbox.EventInterrupt {
+			x, y = screen.Size()
 			if refresh != nil {
 				refresh(x, y)
 			}
 			ClearLine(x, y-1)
 			Printstring(pm, 0, y-1)
-			termbox.SetCursor(plen, y-1)
-			termbox.Flush()
+			screen.SetCursor(plen, y-1)
+			screen.Flush()
 		} else if ev.Type == termbox.EventKey {
 			pressedkey := ParseTermboxEvent(ev)
 			for _, key := range keys {
 				if key == pressedkey {
-					return key
+					return key, nil
 				}
 			}
 		}
 	}
 }
 
-func yesNoChoice(p string, allowcancel bool, refresh func(int, int)) (bool, error) {
+func yesNoChoice(ctx context.Context, p string, allowcancel bool, refresh func(int, int)) (bool, error) {
 	if allowcancel {
-		key := PressKey(p, refresh, "y", "n", "C-g")
+		key, err := PressKeyContext(ctx, p, refresh, "y", "n", "C-g", "C-c")
+		if err != nil {
+			return false, err
+		}
 		switch key {
 		case "y":
 			return true, nil
 		case "n":
 			return false, nil
 		case "C-g", "C-c":
-			return false, errors.New("User cancelled")
+			return false, ErrCancelled
 		}
 	}
-	key := PressKey(p, refresh, "y", "n")
-	return key == "y", nil
+	key, err := PressKeyContext(ctx, p, refresh, "y", "n")
+	return key == "y", err
 }
@@ -0,0 +1,148 @@
+package termutil
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// State of an incremental search through the history, started by C-r or C-s.
+type historySearch struct {
+	forward bool
+	query   string
+	// Entry the search started from, and the entry and byte offset of the
+	// current match; match is -1 until something has matched.
+	start, match, at int
+	failing          bool
+	// The editor as it was before the search, restored on C-g.
+	saved lineEditor
+}
+
+func (e *lineEditor) startSearch(forward bool) {
+	h := e.opts.History
+	if h == nil {
+		return
+	}
+	if e.histpos == h.Len() {
+		e.histsave = e.buffer
+	}
+	start := e.histpos
+	if !forward && start == h.Len() {
+		start--
+	}
+	e.search = &historySearch{forward: forward, start: start, match: -1, saved: *e}
+}
+
+func (e *lineEditor) searchLabel() string {
+	label := "i-search)`"
+	if !e.search.forward {
+		label = "reverse-" + label
+	}
+	if e.search.failing {
+		label = "failed " + label
+	}
+	return "(" + label + e.search.query + "': "
+}
+
+func (e *lineEditor) drawSearch(x, y int) {
+	label := e.searchLabel()
+	ClearLine(x, y-1)
+	Printstring(label+e.buffer, 0, y-1)
+	cx := RunewidthStr(label) + RunewidthStr(e.buffer[:e.search.at])
+	if cx >= x {
+		cx = x - 1
+	}
+	screen.SetCursor(cx, y-1)
+	screen.Flush()
+}
+
+// Looks for the query in the history, starting at entry from and moving in
+// the direction of the search. On failure the previous match is kept.
+func (e *lineEditor) searchFrom(from int) {
+	s := e.search
+	entries := e.opts.History.entries
+	step := -1
+	if s.forward {
+		step = 1
+	}
+	for i := from; i >= 0 && i < len(entries); i += step {
+		var idx int
+		if s.forward {
+			idx = strings.Index(entries[i], s.query)
+		} else {
+			idx = strings.LastIndex(entries[i], s.query)
+		}
+		if idx >= 0 {
+			s.match, s.at, s.failing = i, idx, false
+			e.buffer = entries[i]
+			return
+		}
+	}
+	s.failing = true
+}
+
+// Handles a key while searching. Keys which don't control the search end it
+// with the match in the buffer and are then handled as usual.
+func (e *lineEditor) searchKey(cmd, key string, x int) (string, bool) {
+	s := e.search
+	switch {
+	case cmd == "isearch-backward" || cmd == "isearch-forward":
+		s.forward = cmd == "isearch-forward"
+		if s.query == "" {
+			s.query = e.opts.History.lastSearch
+			if s.query == "" {
+				return "", false
+			}
+		}
+		from := s.start
+		if s.match >= 0 {
+			from = s.match - 1
+			if s.forward {
+				from = s.match + 1
+			}
+		}
+		e.searchFrom(from)
+	case cmd == "cancel":
+		*e = s.saved
+	case cmd == "delete-backward-char":
+		if s.query != "" {
+			_, rs := utf8.DecodeLastRuneInString(s.query)
+			s.query = s.query[:len(s.query)-rs]
+		}
+		s.match, s.at, s.failing = -1, 0, false
+		e.buffer = s.saved.buffer
+		if s.query != "" {
+			e.searchFrom(s.start)
+		}
+	case utf8.RuneCountInString(key) == 1:
+		s.query += key
+		from := s.start
+		if s.match >= 0 {
+			from = s.match
+		}
+		e.searchFrom(from)
+	default:
+		e.endSearch()
+		return e.handleKey(cmd, key, x)
+	}
+	return "", false
+}
+
+// Leaves search mode, keeping the match in the buffer with point on it.
+func (e *lineEditor) endSearch() {
+	s := e.search
+	e.search = nil
+	if s.query != "" {
+		e.opts.History.lastSearch = s.query
+	}
+	if s.match < 0 {
+		return
+	}
+	if e.buffer != s.saved.buffer {
+		e.undos = append(e.undos, editState{s.saved.buffer, s.saved.bufpos})
+		e.redos = nil
+	}
+	e.histpos = s.match
+	e.bufpos = s.at
+	e.cursor = RunewidthStr(e.buffer[:e.bufpos])
+	e.offset = 0
+}
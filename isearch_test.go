@@ -0,0 +1,70 @@
+package termutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHistorySearch(t *testing.T) {
+	s := memScreen(t, 60, 5)
+	for _, tc := range []struct {
+		name string
+		keys []string
+		want string
+	}{
+		{"backward", []string{"C-r", "m", "a", "k", "e"}, "make build"},
+		{"repeated", []string{"C-r", "m", "a", "k", "e", "C-r"}, "make test"},
+		{"failing keeps the last match", []string{"C-r", "m", "a", "k", "e", "C-r", "C-r"}, "make test"},
+		{"failing from the start", []string{"C-r", "z", "z"}, "xy"},
+		{"forward", []string{"C-r", "m", "a", "k", "e", "C-r", "C-s"}, "make build"},
+		{"DEL", []string{"C-r", "g", "i", "x", "DEL"}, "git commit"},
+		{"point on the match", []string{"C-r", "c", "o", "m", "C-k"}, "git "},
+		{"C-g", []string{"C-a", "C-r", "g", "i", "t", "C-g", "Z"}, "Zxy"},
+		{"C-g after a failed search", []string{"C-r", "m", "a", "k", "e", "z", "C-g"}, "xy"},
+	} {
+		h := NewHistoryFrom([]string{"make test", "git commit", "make build", "go vet"})
+		got := editKeys(t, s, EditOptions{Default: "xy", History: h}, tc.keys...)
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestHistorySearchAgain(t *testing.T) {
+	s := memScreen(t, 60, 5)
+	h := NewHistoryFrom([]string{"make test", "git commit", "make build"})
+	editKeys(t, s, EditOptions{History: h}, "C-r", "g", "i", "t", "C-e", "C-u")
+	// C-r with nothing typed searches for the last query again.
+	if got := editKeys(t, s, EditOptions{History: h}, "C-r", "C-r"); got != "git commit" {
+		t.Errorf("got %q, want %q", got, "git commit")
+	}
+}
+
+func TestHistorySearchLabel(t *testing.T) {
+	s := memScreen(t, 60, 5)
+	h := NewHistoryFrom([]string{"make test"})
+	s.RecordFrames(true)
+	editKeys(t, s, EditOptions{History: h}, "C-r", "m", "z")
+	frames := s.Frames()
+	for _, want := range []string{"(reverse-i-search)`m': make test", "(failed reverse-i-search)`mz': make test"} {
+		found := false
+		for _, f := range frames {
+			found = found || strings.Contains(f, want)
+		}
+		if !found {
+			t.Errorf("no frame shows %q", want)
+		}
+	}
+}
+
+func TestHistorySearchRebindCancel(t *testing.T) {
+	s := memScreen(t, 60, 5)
+	km := EditKeymap.Copy()
+	km.Unbind("C-g")
+	km.Bind("C-q", "cancel")
+	h := NewHistoryFrom([]string{"git commit"})
+	got := editKeys(t, s, EditOptions{Default: "xy", History: h, Keymap: km}, "C-a", "C-r", "g", "i", "t", "C-q", "Z")
+	if got != "Zxy" {
+		t.Errorf("got %q, want %q", got, "Zxy")
+	}
+}
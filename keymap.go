@@ -0,0 +1,265 @@
+package termutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Keymap maps sequences of keys, written in the notation ParseTermboxEvent
+// produces and separated by spaces (e.g. "C-x C-s" or "M-g g"), to the names
+// of commands. A key may instead lead to a prefix map holding the rest of the
+// sequences that start with it. In sequences, SPC stands for the space bar.
+type Keymap struct {
+	bindings map[string]keyBinding
+}
+
+// What a single key is bound to: a command, or a prefix map.
+type keyBinding struct {
+	command string
+	prefix  *Keymap
+}
+
+// NewKeymap returns an empty Keymap.
+func NewKeymap() *Keymap {
+	return &Keymap{bindings: make(map[string]keyBinding)}
+}
+
+// Builds a keymap from a table of sequences and commands, as the widgets'
+// defaults are written.
+func keymapFrom(bindings map[string]string) *Keymap {
+	km := NewKeymap()
+	for seq, command := range bindings {
+		if err := km.Bind(seq, command); err != nil {
+			panic(err)
+		}
+	}
+	return km
+}
+
+// Copy returns a copy of km which can be changed without affecting km, e.g.
+// to give one prompt different keys from the rest.
+func (km *Keymap) Copy() *Keymap {
+	ret := NewKeymap()
+	for key, b := range km.bindings {
+		if b.prefix != nil {
+			b.prefix = b.prefix.Copy()
+		}
+		ret.bindings[key] = b
+	}
+	return ret
+}
+
+// Splits a key sequence into keys, checking each is a key ParseTermboxEvent
+// can produce.
+func splitKeys(seq string) ([]string, error) {
+	keys := strings.Fields(seq)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("empty key sequence")
+	}
+	for i, key := range keys {
+		if key == "SPC" {
+			keys[i] = " "
+			continue
+		}
+		if _, err := ParseKeyDescription(key); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// Writes keys as a sequence Bind accepts.
+func joinKeys(keys []string) string {
+	ret := make([]string, len(keys))
+	for i, key := range keys {
+		if key == " " {
+			key = "SPC"
+		}
+		ret[i] = key
+	}
+	return strings.Join(ret, " ")
+}
+
+// Finds the map holding the last key of keys, creating prefix maps on the
+// way if create is true.
+func (km *Keymap) prefixFor(keys []string, create bool) (*Keymap, error) {
+	for i, key := range keys[:len(keys)-1] {
+		b, ok := km.bindings[key]
+		if b.prefix == nil {
+			if ok && create {
+				return nil, fmt.Errorf("key sequence %s starts with non-prefix key %s", joinKeys(keys), joinKeys(keys[:i+1]))
+			}
+			if !create {
+				return nil, nil
+			}
+			b = keyBinding{prefix: NewKeymap()}
+			km.bindings[key] = b
+		}
+		km = b.prefix
+	}
+	return km, nil
+}
+
+// Bind binds the key sequence seq to command, creating prefix maps for all
+// but its last key. It fails if a key in seq isn't one ParseTermboxEvent can
+// produce, or if seq extends a sequence already bound to a command.
+func (km *Keymap) Bind(seq, command string) error {
+	keys, err := splitKeys(seq)
+	if err != nil {
+		return err
+	}
+	m, err := km.prefixFor(keys, true)
+	if err != nil {
+		return err
+	}
+	m.bindings[keys[len(keys)-1]] = keyBinding{command: command}
+	return nil
+}
+
+// BindPrefix makes seq a prefix key leading to sub, so that the sequences in
+// sub can be typed after seq. The same sub may be used in several keymaps.
+func (km *Keymap) BindPrefix(seq string, sub *Keymap) error {
+	keys, err := splitKeys(seq)
+	if err != nil {
+		return err
+	}
+	m, err := km.prefixFor(keys, true)
+	if err != nil {
+		return err
+	}
+	m.bindings[keys[len(keys)-1]] = keyBinding{prefix: sub}
+	return nil
+}
+
+// Unbind removes the binding of seq, whether a command or a prefix map.
+func (km *Keymap) Unbind(seq string) {
+	keys, err := splitKeys(seq)
+	if err != nil {
+		return
+	}
+	if m, _ := km.prefixFor(keys, false); m != nil {
+		delete(m.bindings, keys[len(keys)-1])
+	}
+}
+
+// Lookup returns the command seq is bound to, or if seq is a prefix key, the
+// prefix map for the rest of the sequence. Both are empty if seq is unbound.
+func (km *Keymap) Lookup(seq string) (command string, prefix *Keymap) {
+	keys, err := splitKeys(seq)
+	if err != nil {
+		return "", nil
+	}
+	return km.lookup(keys)
+}
+
+func (km *Keymap) lookup(keys []string) (string, *Keymap) {
+	m, _ := km.prefixFor(keys, false)
+	if m == nil {
+		return "", nil
+	}
+	b := m.bindings[keys[len(keys)-1]]
+	return b.command, b.prefix
+}
+
+// Bindings returns every sequence bound to a command, including those in
+// prefix maps, as a map from sequence to command.
+func (km *Keymap) Bindings() map[string]string {
+	ret := make(map[string]string)
+	km.collect(nil, ret, map[*Keymap]bool{})
+	return ret
+}
+
+func (km *Keymap) collect(keys []string, into map[string]string, seen map[*Keymap]bool) {
+	if seen[km] {
+		return
+	}
+	seen[km] = true
+	for key, b := range km.bindings {
+		seq := append(append([]string(nil), keys...), key)
+		if b.prefix != nil {
+			b.prefix.collect(seq, into, seen)
+		} else {
+			into[joinKeys(seq)] = b.command
+		}
+	}
+	delete(seen, km)
+}
+
+// String lists the bindings one per line, sorted by key sequence.
+func (km *Keymap) String() string {
+	bindings := km.Bindings()
+	seqs := make([]string, 0, len(bindings))
+	for seq := range bindings {
+		seqs = append(seqs, seq)
+	}
+	sort.Strings(seqs)
+	var sb strings.Builder
+	for _, seq := range seqs {
+		fmt.Fprintf(&sb, "%s\t%s\n", seq, bindings[seq])
+	}
+	return sb.String()
+}
+
+// ReadKeySequence reads keys until they make up a sequence bound in km, and
+// returns its command along with the keys read. While a prefix is pending it
+// is shown in the echo area at the bottom of the screen, as "C-x -". If the
+// keys aren't bound to anything the command is empty. Pressing C-g, unless
+// it's bound at the top of km, returns ErrCancelled; it returns ctx.Err() if
+// ctx is done first.
+func ReadKeySequence(ctx context.Context, km *Keymap, refresh func(int, int)) (command string, keys []string, err error) {
+	from := recordedKeys()
+	defer appReadKeys(from)
+	ev, err := pollKeyEvent(ctx, refresh)
+	if err != nil {
+		return "", nil, err
+	}
+	return readKeySequence(ctx, km, ParseTermboxEvent(ev), refresh)
+}
+
+// ReadKeySequence with the first key already read, for widgets which read
+// it themselves along with their other events.
+func readKeySequence(ctx context.Context, km *Keymap, key string, refresh func(int, int)) (string, []string, error) {
+	keys := []string{key}
+	for {
+		command, prefix := km.lookup(keys)
+		if prefix == nil {
+			if command == "" && key == "C-g" {
+				return "", keys, ErrCancelled
+			}
+			return command, keys, nil
+		}
+		echo := joinKeys(keys) + " -"
+		drawEcho(echo)
+		for {
+			ev, err := pollEvent(ctx)
+			if err != nil {
+				return "", keys, err
+			}
+			if ev.Type == termbox.EventKey {
+				key = ParseTermboxEvent(ev)
+				break
+			}
+			if refresh != nil {
+				refresh(screen.Size())
+			}
+			drawEcho(echo)
+		}
+		if key == "C-g" {
+			return "", append(keys, key), ErrCancelled
+		}
+		keys = append(keys, key)
+	}
+}
+
+// Shows msg on the bottom line of the screen.
+func drawEcho(msg string) {
+	sx, sy := screen.Size()
+	ClearLine(sx, sy-1)
+	Printstring(msg, 0, sy-1)
+	screen.SetCursor(RunewidthStr(msg), sy-1)
+	screen.Flush()
+}
@@ -0,0 +1,133 @@
+package termutil
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeymapBind(t *testing.T) {
+	km := NewKeymap()
+	for seq, cmd := range map[string]string{"C-x C-s": "save", "C-x 4 f": "find-other", "M-g g": "goto", "C-a": "bol"} {
+		if err := km.Bind(seq, cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cmd, prefix := km.Lookup("C-x C-s"); cmd != "save" || prefix != nil {
+		t.Errorf("C-x C-s: got %q, %v", cmd, prefix)
+	}
+	if cmd, prefix := km.Lookup("C-x 4"); cmd != "" || prefix == nil {
+		t.Errorf("C-x 4: got %q, %v; want a prefix map", cmd, prefix)
+	} else if cmd, _ := prefix.Lookup("f"); cmd != "find-other" {
+		t.Errorf("f in C-x 4's map: got %q", cmd)
+	}
+	if cmd, prefix := km.Lookup("C-x C-f"); cmd != "" || prefix != nil {
+		t.Errorf("unbound C-x C-f: got %q, %v", cmd, prefix)
+	}
+	if err := km.Bind("C-a b", "x"); err == nil {
+		t.Error("no error extending C-a, which is bound to a command")
+	}
+	if err := km.Bind("C-nonsense", "x"); err == nil {
+		t.Error("no error binding an unknown key")
+	}
+	want := "C-a\tbol\nC-x 4 f\tfind-other\nC-x C-s\tsave\nM-g g\tgoto\n"
+	if got := km.String(); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+
+	c := km.Copy()
+	c.Bind("C-x 4 b", "switch-other")
+	c.Unbind("M-g g")
+	if cmd, _ := km.Lookup("C-x 4 b"); cmd != "" {
+		t.Error("binding in a copy changed the original's prefix map")
+	}
+	if cmd, _ := km.Lookup("M-g g"); cmd != "goto" {
+		t.Error("unbinding in a copy changed the original")
+	}
+}
+
+func TestKeymapRebindPrefix(t *testing.T) {
+	km := NewKeymap()
+	km.Bind("C-x C-s", "save")
+	sub := NewKeymap()
+	sub.Bind("k", "kill")
+	if err := km.BindPrefix("C-x", sub); err != nil {
+		t.Fatal(err)
+	}
+	if cmd, _ := km.Lookup("C-x C-s"); cmd != "" {
+		t.Errorf("C-x C-s still bound to %q after replacing C-x's map", cmd)
+	}
+	if cmd, _ := km.Lookup("C-x k"); cmd != "kill" {
+		t.Errorf("C-x k: got %q, want kill", cmd)
+	}
+	// The map is shared, not copied.
+	sub.Bind("b", "buffers")
+	if cmd, _ := km.Lookup("C-x b"); cmd != "buffers" {
+		t.Errorf("C-x b: got %q, want buffers", cmd)
+	}
+	km.Bind("C-x", "plain")
+	if cmd, prefix := km.Lookup("C-x"); cmd != "plain" || prefix != nil {
+		t.Errorf("C-x: got %q, %v after binding it to a command", cmd, prefix)
+	}
+	if cmd, _ := km.Lookup("C-x k"); cmd != "" {
+		t.Errorf("C-x k still bound to %q", cmd)
+	}
+}
+
+func TestReadKeySequence(t *testing.T) {
+	s := memScreen(t, 40, 5)
+	km := NewKeymap()
+	km.Bind("C-x C-s", "save")
+	km.Bind("C-x 4 f", "find-other")
+	km.Bind("C-a", "bol")
+	for _, tc := range []struct {
+		keys []string
+		cmd  string
+		err  error
+	}{
+		{[]string{"C-a"}, "bol", nil},
+		{[]string{"C-x", "C-s"}, "save", nil},
+		{[]string{"C-x", "4", "f"}, "find-other", nil},
+		{[]string{"z"}, "", nil},
+		{[]string{"C-x", "z"}, "", nil},
+		{[]string{"C-x", "4", "C-s"}, "", nil},
+		{[]string{"C-g"}, "", ErrCancelled},
+		{[]string{"C-x", "C-g"}, "", ErrCancelled},
+		{[]string{"C-x", "4", "C-g"}, "", ErrCancelled},
+	} {
+		s.PushKeys(tc.keys...)
+		cmd, keys, err := ReadKeySequence(context.Background(), km, nil)
+		if cmd != tc.cmd || err != tc.err || !reflect.DeepEqual(keys, tc.keys) {
+			t.Errorf("%v: got %q, %v, %v; want %q, %v", tc.keys, cmd, keys, err, tc.cmd, tc.err)
+		}
+	}
+
+	s.PushKeys("C-x", "4", "f")
+	s.RecordFrames(true)
+	ReadKeySequence(context.Background(), km, nil)
+	frames := s.Frames()
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+	for i, want := range []string{"C-x -", "C-x 4 -"} {
+		if !strings.Contains(frames[i], "|"+want+" ") {
+			t.Errorf("frame %d doesn't echo %q:\n%s", i, want, frames[i])
+		}
+	}
+}
+
+func TestEditPrefixKeys(t *testing.T) {
+	s := memScreen(t, 40, 5)
+	km := EditKeymap.Copy()
+	km.Bind("C-x h", "kill-whole-line")
+	opts := EditOptions{Default: "abc", Keymap: km}
+	if got := editKeys(t, s, opts, "C-x", "h", "d"); got != "d" {
+		t.Errorf("got %q, want %q", got, "d")
+	}
+	// C-g abandons the sequence, not the prompt, and unbound sequences do
+	// nothing.
+	if got := editKeys(t, s, opts, "C-x", "C-g", "C-x", "z", "d"); got != "abcd" {
+		t.Errorf("got %q, want %q", got, "abcd")
+	}
+}
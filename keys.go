@@ -0,0 +1,218 @@
+package termutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nsf/termbox-go"
+)
+
+var namedKeys = map[string]termbox.Key{
+	"DEL":         termbox.KeyBackspace2,
+	"TAB":         termbox.KeyTab,
+	"RET":         termbox.KeyEnter,
+	"DOWN":        termbox.KeyArrowDown,
+	"UP":          termbox.KeyArrowUp,
+	"LEFT":        termbox.KeyArrowLeft,
+	"RIGHT":       termbox.KeyArrowRight,
+	"next":        termbox.KeyPgdn,
+	"prior":       termbox.KeyPgup,
+	"Home":        termbox.KeyHome,
+	"End":         termbox.KeyEnd,
+	"deletechar":  termbox.KeyDelete,
+	"insert":      termbox.KeyInsert,
+	"ESC":         termbox.KeyEsc,
+	"SPC":         termbox.KeySpace,
+	"paste-start": KeyPasteStart,
+	"paste-end":   KeyPasteEnd,
+}
+
+// Keys termbox doesn't have, read before and after text pasted into the
+// terminal in bracketed paste mode; see EnableBracketedPaste.
+// ParseTermboxEvent writes them as "paste-start" and "paste-end".
+const (
+	KeyPasteStart termbox.Key = 0xFFFF - 64
+	KeyPasteEnd   termbox.Key = 0xFFFF - 65
+)
+
+// Modifiers termbox doesn't report, set on navigation and function keys when
+// the terminal sends xterm's escape sequences for them held with Shift or
+// Ctrl. ParseTermboxEvent writes them as S- and C-, e.g. "S-UP" or "C-M-f5".
+const (
+	ModShift termbox.Modifier = 1 << 6
+	ModCtrl  termbox.Modifier = 1 << 7
+)
+
+// ModDouble is set on the second of two clicks of a mouse button in the same
+// place within DoubleClickTime. ParseTermboxEvent writes it as double-, e.g.
+// "double-mouse-1".
+const ModDouble termbox.Modifier = 1 << 5
+
+// Names of the mouse buttons and wheel, as ParseTermboxEvent writes them.
+var mouseKeys = map[string]termbox.Key{
+	"mouse-1":       termbox.MouseLeft,
+	"mouse-2":       termbox.MouseMiddle,
+	"mouse-3":       termbox.MouseRight,
+	"mouse-release": termbox.MouseRelease,
+	"wheel-up":      termbox.MouseWheelUp,
+	"wheel-down":    termbox.MouseWheelDown,
+}
+
+// Names a mouse event, e.g. "mouse-1" for a left click, "drag-mouse-1" for
+// moving the mouse with the left button held, or "wheel-up".
+func mouseName(ev termbox.Event) string {
+	for name, k := range mouseKeys {
+		if k != ev.Key {
+			continue
+		}
+		if ev.Mod&ModDouble != 0 {
+			return "double-" + name
+		} else if ev.Mod&termbox.ModMotion != 0 {
+			return "drag-" + name
+		}
+		return name
+	}
+	return ""
+}
+
+// Whether k is a mouse button which can be double clicked or dragged.
+func isMouseButton(k termbox.Key) bool {
+	return k == termbox.MouseLeft || k == termbox.MouseMiddle || k == termbox.MouseRight
+}
+
+// Whether k is one of the navigation and function keys which can be held with
+// Shift or Ctrl.
+func takesModifiers(k termbox.Key) bool {
+	return termbox.KeyArrowRight <= k && k <= termbox.KeyF1
+}
+
+// Writes the modifiers in mod as a prefix to a key's name, in Emacs' order.
+func modifierPrefix(mod termbox.Modifier) string {
+	prefix := ""
+	if mod&ModCtrl != 0 {
+		prefix += "C-"
+	}
+	if mod&termbox.ModAlt != 0 {
+		prefix += "M-"
+	}
+	if mod&ModShift != 0 {
+		prefix += "S-"
+	}
+	return prefix
+}
+
+// Turns a name written by mouseName back into a mouse event at 0,0.
+func parseMouseName(desc string) (termbox.Event, bool) {
+	ev := termbox.Event{Type: termbox.EventMouse}
+	name := desc
+	if strings.HasPrefix(name, "double-") {
+		ev.Mod = ModDouble
+		name = name[len("double-"):]
+	} else if strings.HasPrefix(name, "drag-") {
+		ev.Mod = termbox.ModMotion
+		name = name[len("drag-"):]
+	}
+	k, ok := mouseKeys[name]
+	if !ok || (ev.Mod != 0 && !isMouseButton(k)) {
+		return ev, false
+	}
+	ev.Key = k
+	return ev, true
+}
+
+// Looks up a key with a name rather than a character, such as RET or f5.
+func namedKey(name string) (termbox.Key, bool) {
+	if k, ok := namedKeys[name]; ok {
+		return k, true
+	}
+	if len(name) > 1 && name[0] == 'f' {
+		n, err := strconv.Atoi(name[1:])
+		if err == nil && n >= 1 && n <= 12 {
+			return termbox.KeyF1 - termbox.Key(n-1), true
+		}
+	}
+	return 0, false
+}
+
+// ParseKeyDescription is the reverse of ParseTermboxEvent: it turns a key
+// written in the same notation ("C-M-a", "M-SPC", "f5", "prior", "x"...) back
+// into the key event that produces it, so that bindings read from a config
+// file can be checked and turned into input. It returns an error if desc
+// isn't a key ParseTermboxEvent can produce. SPC on its own is accepted for
+// the space bar, which ParseTermboxEvent writes as " ".
+func ParseKeyDescription(desc string) (termbox.Event, error) {
+	ev := termbox.Event{Type: termbox.EventKey}
+	if desc == "" {
+		return ev, fmt.Errorf("empty key description")
+	}
+	if desc == "SPC" {
+		desc = " "
+	}
+	if ev, ok := parseMouseName(desc); ok {
+		return ev, nil
+	}
+	if utf8.RuneCountInString(desc) == 1 {
+		ev.Ch, _ = utf8.DecodeRuneInString(desc)
+		if ev.Ch == ' ' {
+			ev.Ch = 0
+			ev.Key = termbox.KeySpace
+		}
+		return ev, nil
+	}
+	rest := desc
+	ctrl, shift := false, false
+	if strings.HasPrefix(rest, "C-") {
+		ctrl = true
+		rest = rest[2:]
+	}
+	if strings.HasPrefix(rest, "M-") && len(rest) > 2 {
+		ev.Mod = termbox.ModAlt
+		rest = rest[2:]
+	}
+	if strings.HasPrefix(rest, "S-") && len(rest) > 2 {
+		shift = true
+		rest = rest[2:]
+	}
+	if k, ok := namedKey(rest); ok {
+		if ctrl || shift {
+			if !takesModifiers(k) {
+				return ev, fmt.Errorf("unknown key %q", desc)
+			}
+			if ctrl {
+				ev.Mod |= ModCtrl
+			}
+			if shift {
+				ev.Mod |= ModShift
+			}
+		} else if k == termbox.KeySpace && ev.Mod != termbox.ModAlt {
+			return ev, fmt.Errorf("unknown key %q", desc)
+		}
+		ev.Key = k
+		return ev, nil
+	}
+	if shift {
+		return ev, fmt.Errorf("unknown key %q", desc)
+	}
+	if ctrl {
+		switch {
+		case rest == "_":
+			ev.Key = termbox.KeyCtrlUnderscore
+		case rest == "@":
+			ev.Key = termbox.KeyCtrlSpace
+		case len(rest) == 1 && 'a' <= rest[0] && rest[0] <= 'z':
+			ev.Key = termbox.Key(rest[0] - 96)
+		case rest == "\\" || rest == "]" || rest == "^":
+			ev.Key = termbox.Key(rest[0] - 64)
+		default:
+			return ev, fmt.Errorf("unknown key %q", desc)
+		}
+		return ev, nil
+	}
+	if ev.Mod == termbox.ModAlt && utf8.RuneCountInString(rest) == 1 {
+		ev.Ch, _ = utf8.DecodeRuneInString(rest)
+		return ev, nil
+	}
+	return ev, fmt.Errorf("unknown key %q", desc)
+}
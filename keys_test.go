@@ -0,0 +1,113 @@
+package termutil
+
+import (
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Every key event ParseTermboxEvent can name, with the modifiers it can be
+// read with.
+func allKeyEvents() []termbox.Event {
+	var evs []termbox.Event
+	add := func(ev termbox.Event, mods ...termbox.Modifier) {
+		for _, mod := range mods {
+			ev.Mod = mod
+			evs = append(evs, ev)
+		}
+	}
+	alt := []termbox.Modifier{0, termbox.ModAlt}
+	var all []termbox.Modifier
+	for _, m := range []termbox.Modifier{0, ModShift, ModCtrl, ModShift | ModCtrl} {
+		all = append(all, m, m|termbox.ModAlt)
+	}
+	for k := termbox.Key(0); k <= termbox.KeySpace; k++ {
+		add(termbox.Event{Type: termbox.EventKey, Key: k}, alt...)
+	}
+	add(termbox.Event{Type: termbox.EventKey, Key: termbox.KeyBackspace2}, alt...)
+	for k := termbox.KeyF1; k >= termbox.KeyArrowRight; k-- {
+		add(termbox.Event{Type: termbox.EventKey, Key: k}, all...)
+	}
+	add(termbox.Event{Type: termbox.EventKey, Key: KeyPasteStart}, 0)
+	add(termbox.Event{Type: termbox.EventKey, Key: KeyPasteEnd}, 0)
+	for r := rune('!'); r <= '~'; r++ {
+		add(termbox.Event{Type: termbox.EventKey, Ch: r}, alt...)
+	}
+	for _, r := range "éß日本語" {
+		add(termbox.Event{Type: termbox.EventKey, Ch: r}, alt...)
+	}
+	for _, k := range mouseKeys {
+		if isMouseButton(k) {
+			add(termbox.Event{Type: termbox.EventMouse, Key: k}, 0, ModDouble, termbox.ModMotion)
+		} else {
+			add(termbox.Event{Type: termbox.EventMouse, Key: k}, 0)
+		}
+	}
+	return evs
+}
+
+func TestParseKeyDescriptionRoundTrip(t *testing.T) {
+	for _, ev := range allKeyEvents() {
+		desc := ParseTermboxEvent(ev)
+		back, err := ParseKeyDescription(desc)
+		if err != nil {
+			t.Errorf("%#v is %q, which doesn't parse: %v", ev, desc, err)
+			continue
+		}
+		if got := ParseTermboxEvent(back); got != desc {
+			t.Errorf("%#v is %q, which parses to %#v, which is %q", ev, desc, back, got)
+		}
+	}
+}
+
+func TestParseKeyDescription(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		ev   termbox.Event
+	}{
+		{"C-a", termbox.Event{Type: termbox.EventKey, Key: termbox.KeyCtrlA}},
+		{"C-M-a", termbox.Event{Type: termbox.EventKey, Key: termbox.KeyCtrlA, Mod: termbox.ModAlt}},
+		{"M-x", termbox.Event{Type: termbox.EventKey, Ch: 'x', Mod: termbox.ModAlt}},
+		{`C-\`, termbox.Event{Type: termbox.EventKey, Key: termbox.KeyCtrlBackslash}},
+		{"C-]", termbox.Event{Type: termbox.EventKey, Key: termbox.KeyCtrlRsqBracket}},
+		{"C-^", termbox.Event{Type: termbox.EventKey, Key: termbox.KeyCtrl6}},
+		{"C-_", termbox.Event{Type: termbox.EventKey, Key: termbox.KeyCtrlUnderscore}},
+		{"C-@", termbox.Event{Type: termbox.EventKey, Key: termbox.KeyCtrlSpace}},
+		{"SPC", termbox.Event{Type: termbox.EventKey, Key: termbox.KeySpace}},
+		{"M-SPC", termbox.Event{Type: termbox.EventKey, Key: termbox.KeySpace, Mod: termbox.ModAlt}},
+		{"S-UP", termbox.Event{Type: termbox.EventKey, Key: termbox.KeyArrowUp, Mod: ModShift}},
+		{"C-LEFT", termbox.Event{Type: termbox.EventKey, Key: termbox.KeyArrowLeft, Mod: ModCtrl}},
+		{"C-M-S-Home", termbox.Event{Type: termbox.EventKey, Key: termbox.KeyHome, Mod: ModCtrl | termbox.ModAlt | ModShift}},
+		{"S-f5", termbox.Event{Type: termbox.EventKey, Key: termbox.KeyF5, Mod: ModShift}},
+		{"f12", termbox.Event{Type: termbox.EventKey, Key: termbox.KeyF12}},
+		{"prior", termbox.Event{Type: termbox.EventKey, Key: termbox.KeyPgup}},
+		{"mouse-1", termbox.Event{Type: termbox.EventMouse, Key: termbox.MouseLeft}},
+		{"double-mouse-3", termbox.Event{Type: termbox.EventMouse, Key: termbox.MouseRight, Mod: ModDouble}},
+		{"drag-mouse-2", termbox.Event{Type: termbox.EventMouse, Key: termbox.MouseMiddle, Mod: termbox.ModMotion}},
+		{"wheel-up", termbox.Event{Type: termbox.EventMouse, Key: termbox.MouseWheelUp}},
+	} {
+		ev, err := ParseKeyDescription(tc.desc)
+		if err != nil {
+			t.Errorf("%q: %v", tc.desc, err)
+			continue
+		}
+		if ev != tc.ev {
+			t.Errorf("%q parsed to %#v, want %#v", tc.desc, ev, tc.ev)
+		}
+		want := tc.desc
+		if want == "SPC" {
+			want = " "
+		}
+		if got := ParseTermboxEvent(ev); got != want {
+			t.Errorf("%q parsed to an event written %q", tc.desc, got)
+		}
+	}
+}
+
+func TestParseKeyDescriptionErrors(t *testing.T) {
+	for _, desc := range []string{"", "C-", "C-A", "M-", "foo", "f13", "f0", "C-M-", "M-foo", "S-a", "C-RET", "double-wheel-up", "M-C-a"} {
+		if ev, err := ParseKeyDescription(desc); err == nil {
+			t.Errorf("%q parsed to %#v", desc, ev)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package termutil
+
+// KillRing holds text killed in the line editor so it can be yanked back,
+// like the Emacs kill ring. Prompts share DefaultKillRing unless given their
+// own through EditOptions.
+type KillRing struct {
+	kills []string
+	max   int
+}
+
+// DefaultKillRing is the kill ring used by prompts which don't set
+// EditOptions.KillRing, so text killed in one prompt can be yanked in the
+// next.
+var DefaultKillRing = NewKillRing(60)
+
+// NewKillRing returns an empty KillRing holding at most max kills. A max of 0
+// or less means no limit.
+func NewKillRing(max int) *KillRing {
+	return &KillRing{max: max}
+}
+
+// Kill adds s to the ring as the most recent kill. Empty strings are ignored.
+func (k *KillRing) Kill(s string) {
+	if s == "" {
+		return
+	}
+	k.kills = append(k.kills, s)
+	if k.max > 0 && len(k.kills) > k.max {
+		k.kills = k.kills[len(k.kills)-k.max:]
+	}
+}
+
+// Adds s to the most recent kill, at its start if before is true, as happens
+// when kill commands are repeated.
+func (k *KillRing) appendKill(s string, before bool) {
+	if len(k.kills) == 0 {
+		k.Kill(s)
+	} else if before {
+		k.kills[len(k.kills)-1] = s + k.kills[len(k.kills)-1]
+	} else {
+		k.kills[len(k.kills)-1] += s
+	}
+}
+
+// Get returns the nth most recent kill, wrapping around the ring; Get(0) is
+// what C-y yanks. It returns the empty string if the ring is empty.
+func (k *KillRing) Get(n int) string {
+	if len(k.kills) == 0 {
+		return ""
+	}
+	n %= len(k.kills)
+	if n < 0 {
+		n += len(k.kills)
+	}
+	return k.kills[len(k.kills)-1-n]
+}
+
+// Len returns the number of kills in the ring.
+func (k *KillRing) Len() int {
+	return len(k.kills)
+}
+
+// Entries returns a copy of the ring, most recent kill first.
+func (k *KillRing) Entries() []string {
+	ret := make([]string, len(k.kills))
+	for i := range ret {
+		ret[i] = k.Get(i)
+	}
+	return ret
+}
@@ -0,0 +1,70 @@
+package termutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKillRing(t *testing.T) {
+	k := NewKillRing(2)
+	if k.Get(0) != "" || k.Len() != 0 {
+		t.Errorf("empty ring has %d kills, yanks %q", k.Len(), k.Get(0))
+	}
+	for _, s := range []string{"one", "", "two", "three"} {
+		k.Kill(s)
+	}
+	if want := []string{"three", "two"}; !reflect.DeepEqual(k.Entries(), want) {
+		t.Errorf("got %q, want %q", k.Entries(), want)
+	}
+	if k.Get(2) != "three" || k.Get(-1) != "two" {
+		t.Errorf("Get doesn't wrap: %q, %q", k.Get(2), k.Get(-1))
+	}
+}
+
+func TestKillAndYank(t *testing.T) {
+	s := memScreen(t, 40, 5)
+	for _, tc := range []struct {
+		name  string
+		def   string
+		kills []string
+		keys  []string
+		want  string
+		ring  []string
+	}{
+		{"C-k", "one two", nil, []string{"C-a", "M-f", "C-k"}, "one", []string{" two"}},
+		{"kills forward append", "one two three", nil, []string{"C-a", "M-d", "M-d", "C-k"}, "", []string{"one two three"}},
+		{"kills backward prepend", "one two three", nil, []string{"M-DEL", "M-DEL"}, "one ", []string{"two three"}},
+		{"C-w prepends", "abc def", nil, []string{"C-a", "C-f", "C-@", "C-e", "M-DEL", "C-w"}, "a", []string{"bc def"}},
+		{"moving ends a kill", "one two", nil, []string{"M-DEL", "C-b", "M-DEL"}, " ", []string{"one", "two"}},
+		{"C-y", "<>", []string{"first", "second"}, []string{"C-b", "C-y"}, "<second>", []string{"second", "first"}},
+		{"M-y", "<>", []string{"first", "second"}, []string{"C-b", "C-y", "M-y"}, "<first>", []string{"second", "first"}},
+		{"M-y wraps", "<>", []string{"first", "second"}, []string{"C-b", "C-y", "M-y", "M-y"}, "<second>", []string{"second", "first"}},
+		{"M-y after typing", "", []string{"first", "second"}, []string{"C-y", "x", "M-y"}, "secondx", []string{"second", "first"}},
+		{"M-y without C-y", "", []string{"first"}, []string{"M-y"}, "", []string{"first"}},
+	} {
+		ring := NewKillRing(0)
+		for _, k := range tc.kills {
+			ring.Kill(k)
+		}
+		got := editKeys(t, s, EditOptions{Default: tc.def, KillRing: ring}, tc.keys...)
+		if got != tc.want || !reflect.DeepEqual(ring.Entries(), tc.ring) {
+			t.Errorf("%s: got %q with ring %q; want %q with %q", tc.name, got, ring.Entries(), tc.want, tc.ring)
+		}
+	}
+}
+
+func TestKillRingShared(t *testing.T) {
+	s := memScreen(t, 40, 5)
+	defaults := DefaultKillRing.Entries()
+	ring := NewKillRing(0)
+	editKeys(t, s, EditOptions{Default: "shared", KillRing: ring}, "C-u")
+	if got := editKeys(t, s, EditOptions{KillRing: ring}, "C-y"); got != "shared" {
+		t.Errorf("yanked %q from the shared ring", got)
+	}
+	if got := editKeys(t, s, EditOptions{KillRing: NewKillRing(0)}, "C-y"); got != "" {
+		t.Errorf("yanked %q from another ring", got)
+	}
+	if !reflect.DeepEqual(DefaultKillRing.Entries(), defaults) {
+		t.Errorf("DefaultKillRing changed to %q", DefaultKillRing.Entries())
+	}
+}
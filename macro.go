@@ -0,0 +1,127 @@
+package termutil
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Macro is a recorded sequence of keys, in the notation ParseTermboxEvent
+// produces, which can be played back through the widgets.
+type Macro []string
+
+// The macro being recorded, if any.
+var recorder struct {
+	sync.Mutex
+	on   bool
+	keys Macro
+	// Where the keys the application last read through NextEvent or
+	// ReadKeySequence start in keys, or -1 if a widget has read keys since.
+	// They're what stopped the recording, if anything did.
+	appKeys int
+}
+
+// StartMacro starts recording every key read by a widget, or by NextEvent,
+// until EndMacro is called. Keys replayed by PlayMacro aren't recorded. Any
+// recording already in progress is discarded.
+func StartMacro() {
+	recorder.Lock()
+	defer recorder.Unlock()
+	recorder.on = true
+	recorder.keys = nil
+	recorder.appKeys = -1
+}
+
+// EndMacro stops recording and returns the keys recorded since StartMacro.
+// The keys which ended the recording, e.g. "C-x )", are left out: if the last
+// keys recorded were read by the application through NextEvent or
+// ReadKeySequence, rather than by a widget, they are taken to be those keys.
+func EndMacro() Macro {
+	recorder.Lock()
+	defer recorder.Unlock()
+	recorder.on = false
+	ret := recorder.keys
+	if recorder.appKeys >= 0 {
+		ret = ret[:recorder.appKeys]
+	}
+	recorder.keys = nil
+	recorder.appKeys = -1
+	return ret
+}
+
+// RecordingMacro reports whether a macro is being recorded.
+func RecordingMacro() bool {
+	recorder.Lock()
+	defer recorder.Unlock()
+	return recorder.on
+}
+
+// Adds ev to the macro being recorded, if it's a key.
+func recordKey(ev termbox.Event) {
+	if ev.Type != termbox.EventKey {
+		return
+	}
+	recorder.Lock()
+	defer recorder.Unlock()
+	if recorder.on {
+		recorder.keys = append(recorder.keys, ParseTermboxEvent(ev))
+		recorder.appKeys = -1
+	}
+}
+
+// Returns the number of keys recorded so far.
+func recordedKeys() int {
+	recorder.Lock()
+	defer recorder.Unlock()
+	return len(recorder.keys)
+}
+
+// Notes that the keys recorded from from on were read by the application
+// rather than a widget.
+func appReadKeys(from int) {
+	recorder.Lock()
+	defer recorder.Unlock()
+	if recorder.on && from < len(recorder.keys) {
+		recorder.appKeys = from
+	}
+}
+
+// PlayMacro queues the keys of m, n times over, to be read by the widgets
+// that wait for input next, ahead of any keys from the screen. Nothing is
+// queued if any key in m can't be parsed.
+func PlayMacro(m Macro, n int) error {
+	evs := make([]termbox.Event, len(m))
+	for i, key := range m {
+		ev, err := ParseKeyDescription(key)
+		if err != nil {
+			return err
+		}
+		evs[i] = ev
+	}
+	for ; n > 0; n-- {
+		for _, ev := range evs {
+			postItem(loopItem{ev: ev, replayed: true}, false)
+		}
+	}
+	return nil
+}
+
+// String writes m as a line of keys separated by spaces, with SPC for the
+// space bar, as ParseMacro reads it.
+func (m Macro) String() string {
+	return joinKeys(m)
+}
+
+// ParseMacro reads a macro written by Macro.String, returning an error if any
+// of the keys isn't one ParseTermboxEvent can produce.
+func ParseMacro(s string) (Macro, error) {
+	if strings.TrimSpace(s) == "" {
+		return Macro{}, nil
+	}
+	keys, err := splitKeys(s)
+	if err != nil {
+		return nil, err
+	}
+	return Macro(keys), nil
+}
@@ -0,0 +1,117 @@
+package termutil
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestMacroRecordAndPlay(t *testing.T) {
+	s := memScreen(t, 40, 5)
+	defer EndMacro()
+	km := NewKeymap()
+	km.Bind("C-x (", "start-macro")
+	km.Bind("C-x )", "end-macro")
+	km.Bind("RET", "prompt")
+
+	// An application's loop, which prompts on RET, run until it has read
+	// every key.
+	var m Macro
+	var got []string
+	loop := func() {
+		for s.Pending() > 0 || queued() > 0 {
+			cmd, _, err := ReadKeySequence(context.Background(), km, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch cmd {
+			case "start-macro":
+				StartMacro()
+			case "end-macro":
+				m = EndMacro()
+			case "prompt":
+				got = append(got, Prompt("Say", nil))
+			}
+		}
+	}
+	s.PushKeys("C-x", "(", "RET", "a", "C-b", "b", "RET", "C-x", ")")
+	loop()
+	if want := (Macro{"RET", "a", "C-b", "b", "RET"}); !reflect.DeepEqual(m, want) {
+		t.Fatalf("recorded %q, want %q", m, want)
+	}
+	if RecordingMacro() {
+		t.Error("still recording after EndMacro")
+	}
+
+	// Played back through the loop, with nothing read from the screen;
+	// replayed keys aren't recorded again.
+	StartMacro()
+	if err := PlayMacro(m, 2); err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	loop()
+	if want := []string{"ba", "ba"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("replaying got %q, want %q", got, want)
+	}
+	if s.Pending() != 0 || queued() != 0 {
+		t.Errorf("%d events and %d posted items left over", s.Pending(), queued())
+	}
+	if m := EndMacro(); len(m) != 0 {
+		t.Errorf("recorded replayed keys %q", m)
+	}
+}
+
+func TestMacroNextEvent(t *testing.T) {
+	s := memScreen(t, 40, 5)
+	defer EndMacro()
+	s.PushKeys("f3", "x", "y", "f4")
+	ctx := context.Background()
+	var m Macro
+	for m == nil {
+		ev, err := NextEvent(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch ParseTermboxEvent(ev) {
+		case "f3":
+			StartMacro()
+		case "f4":
+			m = EndMacro()
+		}
+	}
+	if want := (Macro{"x", "y"}); !reflect.DeepEqual(m, want) {
+		t.Errorf("recorded %q, want %q", m, want)
+	}
+
+	// Ended after a widget read the last keys, the macro keeps every key.
+	StartMacro()
+	s.PushKeys("w", "z", "RET")
+	NextEvent(ctx)
+	Prompt("Say", nil)
+	if m, want := EndMacro(), (Macro{"w", "z", "RET"}); !reflect.DeepEqual(m, want) {
+		t.Errorf("recorded %q, want %q", m, want)
+	}
+}
+
+func TestMacroString(t *testing.T) {
+	m := Macro{"C-a", " ", "x", "RET", "M-SPC", "C-x", "f5", "C-M-_", "S-UP", "mouse-1"}
+	text := m.String()
+	if want := "C-a SPC x RET M-SPC C-x f5 C-M-_ S-UP mouse-1"; text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+	back, err := ParseMacro(text)
+	if err != nil || !reflect.DeepEqual(back, m) {
+		t.Errorf("ParseMacro(%q): got %q, %v", text, back, err)
+	}
+	if m, err := ParseMacro("  "); err != nil || len(m) != 0 {
+		t.Errorf("blank macro: got %q, %v", m, err)
+	}
+	if _, err := ParseMacro("a C-nonsense"); err == nil {
+		t.Error("no error for an unknown key")
+	}
+	defer drainPosted()
+	if err := PlayMacro(Macro{"a", "C-nonsense"}, 1); err == nil || queued() != 0 {
+		t.Errorf("playing a bad macro: got %v with %d keys queued", err, queued())
+	}
+}
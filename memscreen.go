@@ -0,0 +1,287 @@
+package termutil
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nsf/termbox-go"
+)
+
+// MemScreen is a Screen held entirely in memory. Instead of reading the
+// keyboard it plays back a script of events, which makes it possible to drive
+// the widgets from tests without a terminal:
+//
+//	s := termutil.NewMemScreen(80, 24)
+//	termutil.SetScreen(s)
+//	s.PushString("hello")
+//	s.PushKeys("C-a", "M-d", "RET")
+//	result := termutil.Prompt("Say", nil)
+//
+// Running out of scripted events panics, since a real widget would block
+// forever at that point, unless SetBlocking has been used to say more events
+// will be pushed from another goroutine.
+type MemScreen struct {
+	mu               sync.Mutex
+	wake             *sync.Cond
+	blocking         bool
+	width, height    int
+	cells            []termbox.Cell
+	cursorX, cursorY int
+	events           []termbox.Event
+	flushes          int
+	recording        bool
+	frames           []string
+}
+
+// NewMemScreen returns a blank width by height MemScreen with no events.
+func NewMemScreen(width, height int) *MemScreen {
+	s := &MemScreen{}
+	s.wake = sync.NewCond(&s.mu)
+	s.resize(width, height)
+	return s
+}
+
+func (s *MemScreen) resize(width, height int) {
+	cells := make([]termbox.Cell, width*height)
+	for i := range cells {
+		cells[i].Ch = ' '
+	}
+	for y := 0; y < height && y < s.height; y++ {
+		for x := 0; x < width && x < s.width; x++ {
+			cells[y*width+x] = s.cells[y*s.width+x]
+		}
+	}
+	s.width, s.height, s.cells = width, height, cells
+	s.cursorX, s.cursorY = -1, -1
+}
+
+func (s *MemScreen) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if x < 0 || y < 0 || x >= s.width || y >= s.height {
+		return
+	}
+	s.cells[y*s.width+x] = termbox.Cell{Ch: ch, Fg: fg, Bg: bg}
+}
+
+func (s *MemScreen) Size() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.width, s.height
+}
+
+func (s *MemScreen) Clear(fg, bg termbox.Attribute) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.cells {
+		s.cells[i] = termbox.Cell{Ch: ' ', Fg: fg, Bg: bg}
+	}
+	return nil
+}
+
+func (s *MemScreen) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushes++
+	if s.recording {
+		s.frames = append(s.frames, s.snapshot())
+	}
+	return nil
+}
+
+func (s *MemScreen) SetCursor(x, y int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cursorX, s.cursorY = x, y
+}
+
+func (s *MemScreen) HideCursor() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cursorX, s.cursorY = -1, -1
+}
+
+// PollEvent pops the next scripted event. Resize events change the size of
+// the screen as they are delivered.
+func (s *MemScreen) PollEvent() termbox.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.events) == 0 {
+		if !s.blocking {
+			panic("termutil: MemScreen ran out of scripted events")
+		}
+		s.wake.Wait()
+	}
+	ev := s.events[0]
+	s.events = s.events[1:]
+	if ev.Type == termbox.EventResize {
+		s.resize(ev.Width, ev.Height)
+	}
+	return ev
+}
+
+// Interrupt puts an EventInterrupt at the front of the script.
+func (s *MemScreen) Interrupt() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append([]termbox.Event{{Type: termbox.EventInterrupt}}, s.events...)
+	s.wake.Broadcast()
+}
+
+// SetBlocking sets whether PollEvent waits for more events to be pushed, or
+// an Interrupt, when the script runs out, rather than panicking. Blocking is
+// for tests which push events from another goroutine, or which expect a
+// widget to be stopped by its context.
+func (s *MemScreen) SetBlocking(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocking = on
+	s.wake.Broadcast()
+}
+
+// PushEvents appends events to the script.
+func (s *MemScreen) PushEvents(evs ...termbox.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, evs...)
+	s.wake.Broadcast()
+}
+
+// PushMouse appends a mouse event to the script, named as ParseTermboxEvent
+// names them ("mouse-1", "wheel-up"...), at cell x, y.
+func (s *MemScreen) PushMouse(button string, x, y int) error {
+	ev, ok := parseMouseName(button)
+	if !ok {
+		return fmt.Errorf("unknown mouse event %q", button)
+	}
+	ev.MouseX, ev.MouseY = x, y
+	s.PushEvents(ev)
+	return nil
+}
+
+// PushPaste appends the events a terminal in bracketed paste mode sends when
+// text is pasted: KeyPasteStart, a key for each character, and KeyPasteEnd.
+func (s *MemScreen) PushPaste(text string) {
+	evs := []termbox.Event{{Type: termbox.EventKey, Key: KeyPasteStart}}
+	for _, r := range text {
+		ev := termbox.Event{Type: termbox.EventKey}
+		switch r {
+		case '\r':
+			ev.Key = termbox.KeyEnter
+		case '\n':
+			ev.Key = termbox.KeyCtrlJ
+		case '\t':
+			ev.Key = termbox.KeyTab
+		case ' ':
+			ev.Key = termbox.KeySpace
+		default:
+			ev.Ch = r
+		}
+		evs = append(evs, ev)
+	}
+	evs = append(evs, termbox.Event{Type: termbox.EventKey, Key: KeyPasteEnd})
+	s.PushEvents(evs...)
+}
+
+// PushKeys appends key events to the script, written in the notation
+// ParseTermboxEvent produces ("C-a", "M-d", "RET", "x"...). Nothing is pushed
+// if any of the keys can't be parsed.
+func (s *MemScreen) PushKeys(keys ...string) error {
+	evs := make([]termbox.Event, 0, len(keys))
+	for _, key := range keys {
+		ev, err := ParseKeyDescription(key)
+		if err != nil {
+			return err
+		}
+		evs = append(evs, ev)
+	}
+	s.PushEvents(evs...)
+	return nil
+}
+
+// PushString appends one key event per rune of str, as if it had been typed.
+func (s *MemScreen) PushString(str string) {
+	for _, ru := range str {
+		if ru == ' ' {
+			s.PushEvents(termbox.Event{Type: termbox.EventKey, Key: termbox.KeySpace})
+		} else {
+			s.PushEvents(termbox.Event{Type: termbox.EventKey, Ch: ru})
+		}
+	}
+}
+
+// PushResize appends a resize event to the script.
+func (s *MemScreen) PushResize(width, height int) {
+	s.PushEvents(termbox.Event{Type: termbox.EventResize, Width: width, Height: height})
+}
+
+// Pending returns the number of scripted events not yet consumed.
+func (s *MemScreen) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+// Cell returns the cell at x, y. Cells outside the screen are blank.
+func (s *MemScreen) Cell(x, y int) termbox.Cell {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cell(x, y)
+}
+
+func (s *MemScreen) cell(x, y int) termbox.Cell {
+	if x < 0 || y < 0 || x >= s.width || y >= s.height {
+		return termbox.Cell{Ch: ' '}
+	}
+	return s.cells[y*s.width+x]
+}
+
+// Line returns the text on row y with trailing spaces removed. The cell after
+// a double-width rune is skipped, as a terminal would draw over it.
+func (s *MemScreen) Line(y int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.line(y)
+}
+
+func (s *MemScreen) line(y int) string {
+	line, _ := s.lineCells(y)
+	return line
+}
+
+// Returns line(y) along with the number of cells it covers: two for each
+// double-width rune, and one for anything else, control characters included.
+// A double-width rune in the last column makes it one more than the width.
+func (s *MemScreen) lineCells(y int) (string, int) {
+	var sb strings.Builder
+	cells := 0
+	for x := 0; x < s.width; x++ {
+		ru := s.cell(x, y).Ch
+		if ru == 0 {
+			ru = ' '
+		}
+		sb.WriteRune(ru)
+		cells++
+		if Runewidth(ru) > 1 && !IsControl(ru) {
+			x++
+			cells++
+		}
+	}
+	line := strings.TrimRight(sb.String(), " ")
+	return line, cells - (sb.Len() - len(line))
+}
+
+// Cursor returns the cursor position, or -1, -1 if it is hidden.
+func (s *MemScreen) Cursor() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursorX, s.cursorY
+}
+
+// Flushes returns how many times Flush has been called.
+func (s *MemScreen) Flushes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flushes
+}
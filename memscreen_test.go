@@ -0,0 +1,185 @@
+package termutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Installs a w by h MemScreen for the length of the test.
+func memScreen(t *testing.T, w, h int) *MemScreen {
+	t.Helper()
+	s := NewMemScreen(w, h)
+	SetScreen(s)
+	t.Cleanup(func() { SetScreen(nil) })
+	return s
+}
+
+func TestEditDynamicWithCallback(t *testing.T) {
+	s := memScreen(t, 40, 5)
+	s.PushString("hello world")
+	if err := s.PushKeys("C-a", "M-d", "RET"); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	got := EditDynamicWithCallback("", "Say", nil, func(buf, key string) string {
+		keys = append(keys, key)
+		return buf
+	})
+	if got != " world" {
+		t.Fatalf("got %q, want %q", got, " world")
+	}
+	if len(keys) != len("hello world")+3 || keys[len(keys)-1] != "RET" {
+		t.Errorf("callback called for %q", keys)
+	}
+	if line := s.Line(4); line != "Say:  world" {
+		t.Errorf("echo area %q", line)
+	}
+
+	s.PushKeys("C-e", "DEL", "DEL", "RET")
+	if got := EditDynamicWithCallback("default", "Edit", nil, nil); got != "defau" {
+		t.Errorf("got %q, want %q", got, "defau")
+	}
+
+	// What the callback returns replaces the buffer.
+	s.PushString("abc")
+	s.PushKeys("RET")
+	got = EditDynamicWithCallback("", "Up", nil, func(buf, key string) string {
+		if key == "RET" {
+			return strings.ToUpper(buf)
+		}
+		return buf
+	})
+	if got != "ABC" {
+		t.Errorf("got %q, want %q", got, "ABC")
+	}
+}
+
+func TestChoiceIndexCallback(t *testing.T) {
+	s := memScreen(t, 40, 5)
+	choices := []string{"a", "b", "c"}
+	s.PushKeys("C-n", "C-n", "RET")
+	var sels []int
+	got := ChoiceIndexCallback("Pick", choices, 0, func(sel, sx, sy int) {
+		if sx != 40 || sy != 5 {
+			t.Errorf("callback got size %dx%d", sx, sy)
+		}
+		sels = append(sels, sel)
+	})
+	if got != 2 {
+		t.Fatalf("got %d, want 2", got)
+	}
+	if len(sels) != 3 || sels[0] != 0 || sels[1] != 1 || sels[2] != 2 {
+		t.Errorf("callback saw selections %v", sels)
+	}
+
+	s.PushKeys("C-p", "C-g")
+	if got := ChoiceIndexCallback("Pick", choices, 1, nil); got != 1 {
+		t.Errorf("cancelled: got %d, want the default 1", got)
+	}
+}
+
+func TestDisplayScreenMessage(t *testing.T) {
+	s := memScreen(t, 20, 4)
+	s.PushKeys("C-n", "C-n")
+	s.PushKeys("q")
+	s.RecordFrames(true)
+	DisplayScreenMessage("one\ntwo", "three\nfour\nfive")
+	frames := s.Frames()
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames, want 3", len(frames))
+	}
+	if !strings.Contains(frames[2], "|three               |\n|four                |\n|five                |\n") {
+		t.Errorf("after scrolling down twice:\n%s", frames[2])
+	}
+	if s.Pending() != 0 {
+		t.Errorf("%d events left over", s.Pending())
+	}
+}
+
+func TestYesNoCancel(t *testing.T) {
+	s := memScreen(t, 40, 5)
+	for _, tc := range []struct {
+		keys []string
+		want bool
+		err  error
+	}{
+		{[]string{"y"}, true, nil},
+		{[]string{"n"}, false, nil},
+		{[]string{"x", "y"}, true, nil},
+		{[]string{"C-g"}, false, ErrCancelled},
+		{[]string{"C-c"}, false, ErrCancelled},
+	} {
+		s.PushKeys(tc.keys...)
+		got, err := YesNoCancel("Sure?", nil)
+		if got != tc.want || err != tc.err {
+			t.Errorf("%v: got %v, %v; want %v, %v", tc.keys, got, err, tc.want, tc.err)
+		}
+	}
+	if line := s.Line(4); line != "Sure? (y/n/C-g/C-c)" {
+		t.Errorf("prompt %q", line)
+	}
+}
+
+func TestMemScreenRunsOut(t *testing.T) {
+	memScreen(t, 40, 5)
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "ran out of scripted events") {
+			t.Errorf("got %v, want a panic about running out of events", r)
+		}
+	}()
+	Prompt("Say", nil)
+}
+
+func TestMemScreenBlocking(t *testing.T) {
+	s := memScreen(t, 40, 5)
+	s.SetBlocking(true)
+	done := make(chan string)
+	go func() {
+		done <- Prompt("Say", nil)
+	}()
+	s.PushString("hi")
+	select {
+	case got := <-done:
+		t.Fatalf("returned %q before RET", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+	s.PushKeys("RET")
+	select {
+	case got := <-done:
+		if got != "hi" {
+			t.Errorf("got %q, want %q", got, "hi")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Prompt didn't return")
+	}
+}
+
+func TestPushKeysRejectsUnknownKeys(t *testing.T) {
+	s := NewMemScreen(10, 2)
+	if err := s.PushKeys("a", "C-nonsense"); err == nil {
+		t.Error("no error for an unknown key")
+	}
+	if s.Pending() != 0 {
+		t.Errorf("%d events pushed despite the error", s.Pending())
+	}
+}
+
+func TestDisplayScreenMessageWheel(t *testing.T) {
+	s := memScreen(t, 20, 4)
+	s.PushMouse("wheel-down", 0, 0)
+	s.PushMouse("wheel-down", 0, 0)
+	s.PushMouse("wheel-up", 0, 0)
+	s.PushKeys("q")
+	s.RecordFrames(true)
+	DisplayScreenMessage("one\ntwo\nthree\nfour")
+	frames := s.Frames()
+	if len(frames) != 4 {
+		t.Fatalf("got %d frames, want 4", len(frames))
+	}
+	for i, top := range []string{"one", "two", "three", "two"} {
+		if !strings.Contains(frames[i], "\n|"+top+" ") {
+			t.Errorf("frame %d doesn't start at %q:\n%s", i, top, frames[i])
+		}
+	}
+}
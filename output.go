@@ -1,6 +1,7 @@
 package termutil
 
 import (
+	"context"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -17,7 +18,7 @@ func WordCharacter(c rune) bool {
 //Pass the screenwidth and a line number; this function will clear the given line.
 func ClearLine(sx, y int) {
 	for i := 0; i < sx; i++ {
-		termbox.SetCell(i, y, ' ', termbox.ColorDefault, termbox.ColorDefault)
+		screen.SetCell(i, y, ' ', termbox.ColorDefault, termbox.ColorDefault)
 	}
 }
 
@@ -58,15 +59,20 @@ func PrintRune(x, y int, ru rune, col termbox.Attribute) {
 
 //Print the rune with reverse colors for control characters
 func PrintRuneBgFg(x, y int, ru rune, fg, bg termbox.Attribute) {
+	printRune(screen.SetCell, x, y, ru, fg, bg)
+}
+
+// PrintRuneBgFg, drawing with setCell.
+func printRune(setCell func(x, y int, ch rune, fg, bg termbox.Attribute), x, y int, ru rune, fg, bg termbox.Attribute) {
 	if IsControl(ru) {
 		if ru <= rune(26) {
-			termbox.SetCell(x, y, '^', fg|termbox.AttrReverse, bg)
-			termbox.SetCell(x+1, y, '@'+ru, fg|termbox.AttrReverse, bg)
+			setCell(x, y, '^', fg|termbox.AttrReverse, bg)
+			setCell(x+1, y, '@'+ru, fg|termbox.AttrReverse, bg)
 		} else {
-			termbox.SetCell(x, y, '�', fg, bg)
+			setCell(x, y, '�', fg, bg)
 		}
 	} else {
-		termbox.SetCell(x, y, ru, fg, bg)
+		setCell(x, y, ru, fg, bg)
 	}
 }
 
@@ -92,13 +98,10 @@ func PrintStringFgBg(x, y int, s string, fg, bg termbox.Attribute) {
 
 func pauseForAnyKey(currentRow int) {
 	Printstring("<More>", 0, currentRow)
-	termbox.Flush()
-	ev := termbox.PollEvent()
-	for ev.Type != termbox.EventKey {
-		ev = termbox.PollEvent()
-	}
-	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
-	termbox.Flush()
+	screen.Flush()
+	pollKeyEvent(context.Background(), nil)
+	screen.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	screen.Flush()
 }
 
 type lessRow struct {
@@ -120,13 +123,59 @@ func lessDrawRows(sx, sy, cx, cy int, rows []lessRow, numrows int) {
 		PrintRune(i, sy-1, ' ', termbox.AttrReverse)
 	}
 	PrintstringColored(termbox.AttrReverse, "^C, ^G, q to quit. Arrow keys/Vi keys/Emacs keys to move.", 0, sy-1)
-	termbox.Flush()
+	screen.Flush()
+}
+
+// PagerKeymap holds the keys of DisplayScreenMessage, bound to the names of
+// its commands; see DefaultPagerKeymap.
+var PagerKeymap = DefaultPagerKeymap()
+
+// DefaultPagerKeymap returns a new copy of DisplayScreenMessage's default
+// keys.
+func DefaultPagerKeymap() *Keymap {
+	return keymapFrom(map[string]string{
+		"q":          "quit",
+		"C-c":        "quit",
+		"C-g":        "quit",
+		"DOWN":       "next-line",
+		"j":          "next-line",
+		"C-n":        "next-line",
+		"UP":         "previous-line",
+		"k":          "previous-line",
+		"C-p":        "previous-line",
+		"Home":       "beginning-of-line",
+		"C-a":        "beginning-of-line",
+		"LEFT":       "scroll-left",
+		"h":          "scroll-left",
+		"C-b":        "scroll-left",
+		"RIGHT":      "scroll-right",
+		"l":          "scroll-right",
+		"C-f":        "scroll-right",
+		"next":       "next-page",
+		"C-v":        "next-page",
+		"prior":      "previous-page",
+		"M-v":        "previous-page",
+		"g":          "beginning-of-buffer",
+		"M-<":        "beginning-of-buffer",
+		"G":          "end-of-buffer",
+		"M->":        "end-of-buffer",
+		"/":          "search",
+		"C-s":        "search",
+		"wheel-up":   "previous-line",
+		"wheel-down": "next-line",
+	})
 }
 
 //Prints all strings given to the screen, and allows the user to scroll through,
 //rather like less(1).
 func DisplayScreenMessage(messages ...string) {
-	termbox.HideCursor()
+	DisplayScreenMessageContext(context.Background(), messages...)
+}
+
+// DisplayScreenMessageContext is DisplayScreenMessage, but returns ctx.Err()
+// if ctx is done before the user quits.
+func DisplayScreenMessageContext(ctx context.Context, messages ...string) error {
+	screen.HideCursor()
 	rows := make([]lessRow, 0)
 	for _, msg := range messages {
 		for _, s := range strings.Split(msg, "\n") {
@@ -137,55 +186,66 @@ func DisplayScreenMessage(messages ...string) {
 	numrows := len(rows)
 	cy := 0
 	cx := 0
-	done := false
-	for !done {
-		termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
-		sx, sy := termbox.Size()
+	for {
+		screen.Clear(termbox.ColorDefault, termbox.ColorDefault)
+		sx, sy := screen.Size()
 		if sy > numrows {
 			cy = 0
 		}
 		lessDrawRows(sx, sy, cx, cy, rows, numrows)
 
-		ev := termbox.PollEvent()
-		if ev.Type == termbox.EventKey {
-			switch ParseTermboxEvent(ev) {
-			case "q", "C-c", "C-g":
-				done = true
-			case "DOWN", "j", "C-n":
+		ev, err := pollEvent(ctx)
+		if err != nil {
+			return err
+		}
+		if ev.Type == termbox.EventKey || ev.Type == termbox.EventMouse {
+			cmd, _, err := readKeySequence(ctx, PagerKeymap, ParseTermboxEvent(ev), nil)
+			if err == ErrCancelled {
+				continue
+			} else if err != nil {
+				return err
+			}
+			switch cmd {
+			case "quit":
+				return nil
+			case "next-line":
 				if cy < numrows+1-sy {
 					cy++
 				}
-			case "UP", "k", "C-p":
+			case "previous-line":
 				if cy > 0 {
 					cy--
 				}
-			case "Home", "C-a":
+			case "beginning-of-line":
 				cx = 0
-			case "LEFT", "h", "C-b":
+			case "scroll-left":
 				if cx > 0 {
 					cx--
 				}
-			case "RIGHT", "l", "C-f":
+			case "scroll-right":
 				cx++
-			case "next", "C-v":
+			case "next-page":
 				cy += sy - 2
 				if cy > numrows+1-sy {
 					cy = numrows + 1 - sy
 				}
-			case "prior", "M-v":
+			case "previous-page":
 				cy -= sy - 2
 				if cy < 0 {
 					cy = 0
 				}
-			case "g", "M-<":
+			case "beginning-of-buffer":
 				cy = 0
-			case "G", "M->":
+			case "end-of-buffer":
 				cy = numrows + 1 - sy
-			case "/", "C-s":
-				search := Prompt("Search", func(ssx, ssy int) {
+			case "search":
+				search, err := PromptContext(ctx, "Search", func(ssx, ssy int) {
 					lessDrawRows(ssx, ssy, cx, cy, rows, numrows)
 				})
-				termbox.HideCursor()
+				if err != nil && err != ErrCancelled {
+					return err
+				}
+				screen.HideCursor()
 				for offset, row := range rows[cy:] {
 					if strings.Contains(row.data, search) {
 						cy += offset
@@ -0,0 +1,87 @@
+package termutil
+
+import (
+	"context"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// NewlinePolicy says what a prompt does with line breaks in pasted text.
+type NewlinePolicy int
+
+const (
+	// NewlinesToSpaces replaces each line break with a space.
+	NewlinesToSpaces NewlinePolicy = iota
+	// KeepNewlines inserts line breaks as they are, as ^J.
+	KeepNewlines
+	// DropNewlines removes line breaks, joining the lines.
+	DropNewlines
+	// FirstLineOnly inserts only the first line of the text.
+	FirstLineOnly
+)
+
+// Applies the policy to text, whose line breaks may be \r, \n or \r\n.
+func (p NewlinePolicy) apply(text string) string {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	switch p {
+	case KeepNewlines:
+		return text
+	case DropNewlines:
+		return strings.Replace(text, "\n", "", -1)
+	case FirstLineOnly:
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			return text[:i]
+		}
+		return text
+	default:
+		return strings.Replace(text, "\n", " ", -1)
+	}
+}
+
+// Reads the keys of a bracketed paste, after its KeyPasteStart, up to its
+// KeyPasteEnd and returns them as text.
+func readPaste(ctx context.Context) (string, error) {
+	var sb strings.Builder
+	for {
+		ev, err := pollEvent(ctx)
+		if err != nil {
+			return "", err
+		}
+		if ev.Type != termbox.EventKey {
+			continue
+		}
+		switch {
+		case ev.Ch != 0:
+			sb.WriteRune(ev.Ch)
+		case ev.Key == KeyPasteEnd:
+			return sb.String(), nil
+		case ev.Key == termbox.KeySpace:
+			sb.WriteByte(' ')
+		case ev.Key == termbox.KeyTab:
+			sb.WriteByte('\t')
+		case ev.Key == termbox.KeyEnter:
+			sb.WriteByte('\r')
+		case ev.Key == termbox.KeyCtrlJ:
+			sb.WriteByte('\n')
+		}
+	}
+}
+
+// Inserts pasted text at point as a single change, running the callback once
+// with the key "paste".
+func (e *lineEditor) paste(text string) {
+	if e.search != nil {
+		e.endSearch()
+	}
+	e.completions = nil
+	text = e.opts.PasteNewlines.apply(text)
+	before := editState{e.buffer, e.bufpos}
+	e.buffer = e.buffer[:e.bufpos] + text + e.buffer[e.bufpos:]
+	e.setPoint(e.bufpos + len(text))
+	e.thisCmd = "paste"
+	e.recordUndo(before)
+	e.lastCmd = e.thisCmd
+	e.runCallback("paste")
+}
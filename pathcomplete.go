@@ -0,0 +1,94 @@
+package termutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathCompleter is a Completer for file system paths. It completes the last
+// segment of the path before point, marking directories with a trailing
+// slash. Dotfiles are only offered when the segment typed so far starts with
+// a dot.
+type PathCompleter struct {
+	// Base is the directory relative paths are completed from. If empty,
+	// the current directory is used.
+	Base string
+	// Extensions, if not empty, restricts the files offered to those with
+	// one of these extensions, e.g. ".go". Directories are always offered.
+	Extensions []string
+}
+
+// ExpandPath turns a path typed at a prompt into one which can be opened: a
+// leading ~ is replaced with the user's home directory, and relative paths
+// are taken relative to base (or the current directory if base is empty).
+func ExpandPath(path, base string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = home + path[1:]
+		}
+	}
+	if filepath.IsAbs(path) || base == "" {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
+func (p PathCompleter) Complete(buffer string, pos int) ([]string, int, int) {
+	typed := buffer[:pos]
+	if typed == "~" {
+		return []string{"~/"}, 0, pos
+	}
+	start := strings.LastIndexAny(typed, "/"+string(filepath.Separator)) + 1
+	dir, segment := typed[:start], typed[start:]
+	lookin := ExpandPath(dir, p.Base)
+	if lookin == "" {
+		lookin = "."
+	}
+	entries, err := os.ReadDir(lookin)
+	if err != nil {
+		return nil, start, pos
+	}
+	var ret []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, segment) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(segment, ".") {
+			continue
+		}
+		isdir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			if target, err := os.Stat(filepath.Join(lookin, name)); err == nil {
+				isdir = target.IsDir()
+			}
+		}
+		if isdir {
+			ret = append(ret, name+"/")
+		} else if p.matchesExtension(name) {
+			ret = append(ret, name)
+		}
+	}
+	return ret, start, pos
+}
+
+func (p PathCompleter) matchesExtension(name string) bool {
+	if len(p.Extensions) == 0 {
+		return true
+	}
+	ext := filepath.Ext(name)
+	for _, want := range p.Extensions {
+		if strings.EqualFold(ext, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// PromptPath is Prompt with TAB completion of file paths relative to base,
+// offering only files with the given extensions if any are given. It returns
+// the path as typed; use ExpandPath to resolve it.
+func PromptPath(prompt string, refresh func(int, int), base string, extensions ...string) string {
+	return PromptWithCompleter(prompt, refresh, PathCompleter{Base: base, Extensions: extensions})
+}
@@ -0,0 +1,87 @@
+// +build !windows
+
+package termutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Makes a directory to complete paths in.
+func pathTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, d := range []string{"beta", ".config"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"alpha.go", "alpine.txt", ".hidden", "beta/x.go"} {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("beta", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestPathCompleter(t *testing.T) {
+	dir := pathTree(t)
+	t.Setenv("HOME", dir)
+	for _, tc := range []struct {
+		typed string
+		exts  []string
+		want  []string
+		start int
+	}{
+		{"", nil, []string{"alpha.go", "alpine.txt", "beta/", "link/"}, 0},
+		{"al", nil, []string{"alpha.go", "alpine.txt"}, 0},
+		{"alp", []string{".GO"}, []string{"alpha.go"}, 0},
+		{"", []string{".go"}, []string{"alpha.go", "beta/", "link/"}, 0},
+		{".", nil, []string{".config/", ".hidden"}, 0},
+		{"beta/", nil, []string{"x.go"}, 5},
+		{"link/x", nil, []string{"x.go"}, 5},
+		{"./b", nil, []string{"beta/"}, 2},
+		{"~", nil, []string{"~/"}, 0},
+		{"~/l", nil, []string{"link/"}, 2},
+		{dir + "/alpi", nil, []string{"alpine.txt"}, len(dir) + 1},
+		{"z", nil, nil, 0},
+		{"missing/", nil, nil, 8},
+	} {
+		got, start, end := PathCompleter{Base: dir, Extensions: tc.exts}.Complete(tc.typed+"|rest", len(tc.typed))
+		if !reflect.DeepEqual(got, tc.want) || start != tc.start || end != len(tc.typed) {
+			t.Errorf("%q: got %q, %d, %d; want %q, %d, %d", tc.typed, got, start, end, tc.want, tc.start, len(tc.typed))
+		}
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+	for _, tc := range []struct {
+		path, base, want string
+	}{
+		{"~", "", "/home/me"},
+		{"~/notes", "/base", "/home/me/notes"},
+		{"~other", "", "~other"},
+		{"notes", "/base", "/base/notes"},
+		{"notes", "", "notes"},
+		{"/abs", "/base", "/abs"},
+	} {
+		if got := ExpandPath(tc.path, tc.base); got != tc.want {
+			t.Errorf("ExpandPath(%q, %q): got %q, want %q", tc.path, tc.base, got, tc.want)
+		}
+	}
+}
+
+func TestPromptPath(t *testing.T) {
+	s := memScreen(t, 40, 10)
+	dir := pathTree(t)
+	s.PushKeys("b", "TAB", "TAB", "RET")
+	if got := PromptPath("Open", nil, dir); got != "beta/x.go" {
+		t.Errorf("got %q, want %q", got, "beta/x.go")
+	}
+}
@@ -0,0 +1,113 @@
+package termutil
+
+import (
+	"github.com/nsf/termbox-go"
+)
+
+// Region is a rectangle for a widget's client to draw in, such as the
+// preview of ChoiceOptions. Coordinates are relative to its top left corner,
+// and anything drawn outside it is clipped.
+type Region struct {
+	width, height int
+	cells         []termbox.Cell
+}
+
+func newRegion(width, height int) *Region {
+	return &Region{width: width, height: height, cells: make([]termbox.Cell, width*height)}
+}
+
+// Size returns the width and height of the region.
+func (r *Region) Size() (int, int) {
+	return r.width, r.height
+}
+
+// SetCell sets the cell at x, y as termbox.SetCell does. Runes which would
+// stick out of the region are left out.
+func (r *Region) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	if x < 0 || y < 0 || x+Runewidth(ch) > r.width || y >= r.height {
+		return
+	}
+	r.cells[y*r.width+x] = termbox.Cell{Ch: ch, Fg: fg, Bg: bg}
+}
+
+// PrintStringFgBg prints s at x, y as the package's PrintStringFgBg does,
+// stopping at the edge of the region.
+func (r *Region) PrintStringFgBg(x, y int, s string, fg, bg termbox.Attribute) {
+	for _, ru := range s {
+		w := Runewidth(ru)
+		if x+w > r.width {
+			return
+		}
+		printRune(r.SetCell, x, y, ru, fg, bg)
+		x += w
+	}
+}
+
+// Printstring prints s at x, y in the default colors.
+func (r *Region) Printstring(s string, x, y int) {
+	r.PrintStringFgBg(x, y, s, termbox.ColorDefault, termbox.ColorDefault)
+}
+
+// Copies the cells drawn in r to the screen at x, y.
+func (r *Region) draw(x, y int) {
+	for i, cell := range r.cells {
+		if cell.Ch != 0 {
+			screen.SetCell(x+i%r.width, y+i/r.width, cell.Ch, cell.Fg, cell.Bg)
+		}
+	}
+}
+
+// Works out the part of an sx by sy screen the list takes up, which is all
+// of it unless there's a preview.
+func (c *chooser) layout(sx, sy int) {
+	c.lw, c.lh = sx, sy
+	if c.preview == nil {
+		return
+	}
+	split := c.split
+	if split <= 0 || split >= 1 {
+		split = 0.5
+	}
+	if c.below {
+		c.lh = clampInt(int(float64(sy)*split), 2, sy-2)
+	} else {
+		c.lw = clampInt(int(float64(sx)*split), 1, sx-2)
+	}
+}
+
+// Draws the preview of the selected choice beside or under the list, with a
+// line between them, calling the preview function again only if the
+// selection or the space for it has changed since the last time.
+func (c *chooser) drawPreview(sx, sy int) {
+	px, py := c.lw+1, 1
+	if c.below {
+		px, py = 0, c.lh+1
+		for x := 0; x < sx; x++ {
+			screen.SetCell(x, c.lh, '─', termbox.ColorDefault, termbox.ColorDefault)
+		}
+	} else {
+		for y := 1; y < sy; y++ {
+			screen.SetCell(c.lw, y, '│', termbox.ColorDefault, termbox.ColorDefault)
+		}
+	}
+	if len(c.rows) == 0 || sx-px <= 0 || sy-py <= 0 {
+		return
+	}
+	sel := c.rows[c.selection]
+	if c.pane == nil || c.paneSel != sel || c.pane.width != sx-px || c.pane.height != sy-py {
+		c.pane = newRegion(sx-px, sy-py)
+		c.paneSel = sel
+		c.preview(sel, c.pane)
+	}
+	c.pane.draw(px, py)
+}
+
+func clampInt(n, lo, hi int) int {
+	if n > hi {
+		n = hi
+	}
+	if n < lo {
+		n = lo
+	}
+	return n
+}
@@ -0,0 +1,82 @@
+package termutil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	s := memScreen(t, 40, 10)
+	items := choiceLabels([]string{"apple", "banana", "cherry"})
+	type call struct{ sel, w, h int }
+	var calls []call
+	opts := ChoiceOptions{Preview: func(sel int, r *Region) {
+		w, h := r.Size()
+		calls = append(calls, call{sel, w, h})
+		r.Printstring("preview of "+items[sel].Label, 0, 0)
+	}}
+	s.PushKeys("C-n", "C-n", "C-p")
+	// Filtering to the choice already selected, and to nothing, doesn't
+	// preview it again.
+	s.PushKeys("b", "z", "DEL")
+	s.PushResize(50, 10)
+	s.PushKeys("RET")
+	s.RecordFrames(true)
+	if got, err := ChoiceWithOptions("Fruit", items, opts); got != 1 || err != nil {
+		t.Errorf("got %d, %v; want 1", got, err)
+	}
+	want := []call{{0, 19, 9}, {1, 19, 9}, {2, 19, 9}, {1, 19, 9}, {1, 24, 9}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("preview called with %v, want %v", calls, want)
+	}
+	frame := s.Frames()[0]
+	if !strings.Contains(frame, "\n| > apple            │preview of apple   |\n") {
+		t.Errorf("preview not drawn beside the list:\n%s", frame)
+	}
+	if frame := s.Frames()[5]; strings.Contains(frame, "preview of") {
+		t.Errorf("preview drawn with nothing selected:\n%s", frame)
+	}
+}
+
+func TestPreviewBelow(t *testing.T) {
+	s := memScreen(t, 20, 8)
+	items := choiceLabels([]string{"a", "b", "c"})
+	opts := ChoiceOptions{Split: 0.5, PreviewBelow: true, Preview: func(sel int, r *Region) {
+		for y := 0; y < 10; y++ {
+			r.Printstring(strings.Repeat(items[sel].Label, 30), 0, y)
+		}
+	}}
+	s.PushKeys("C-n", "RET")
+	if got, _ := ChoiceWithOptions("Pick", items, opts); got != 1 {
+		t.Errorf("got %d, want 1", got)
+	}
+	// Three rows of the list, a rule, and the preview clipped to the
+	// three lines left.
+	for y, want := range []string{"Pick", "   a", " > b", "   c", strings.Repeat("─", 20), strings.Repeat("b", 20), strings.Repeat("b", 20), strings.Repeat("b", 20)} {
+		if got := s.Line(y); got != want {
+			t.Errorf("line %d: got %q, want %q", y, got, want)
+		}
+	}
+}
+
+func TestRegion(t *testing.T) {
+	r := newRegion(5, 2)
+	r.Printstring("abcdefg", 0, 0)
+	r.Printstring("日本語", 0, 1)
+	r.SetCell(-1, 0, 'x', 0, 0)
+	r.SetCell(0, 2, 'x', 0, 0)
+	var got []string
+	for y := 0; y < 2; y++ {
+		var sb strings.Builder
+		for x := 0; x < 5; x++ {
+			if ch := r.cells[y*5+x].Ch; ch != 0 {
+				sb.WriteRune(ch)
+			}
+		}
+		got = append(got, sb.String())
+	}
+	if want := []string{"abcde", "日本"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -3,36 +3,346 @@
 package termutil
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/nsf/termbox-go"
 )
 
-//Get a raw character from termbox
-func GetRawChar(refresh func(int, int)) string {
-	done := false
-	chara := ""
-	for !done {
-		data := make([]byte, 4)
-		i := termbox.PollRawEvent(data)
-		parsed := termbox.ParseEvent(data)
-		if parsed.Type == termbox.EventKey {
-			chara = string(data[:i.N])
-			done = true
-		} else if parsed.Type == termbox.EventResize && refresh != nil {
-			refresh(termbox.Size())
-		}
-	}
-	return chara
+// PollRawEvent reads the raw bytes of the next event from termbox.
+func (TermboxScreen) PollRawEvent(data []byte) termbox.Event {
+	return termbox.PollRawEvent(data)
+}
+
+// ParseEvent parses raw bytes read by PollRawEvent.
+func (TermboxScreen) ParseEvent(data []byte) termbox.Event {
+	return termbox.ParseEvent(data)
+}
+
+// GetRawCharContext is GetRawChar, giving up with ctx.Err() if ctx is done
+// first.
+func GetRawCharContext(ctx context.Context, refresh func(int, int)) (string, error) {
+	ev, err := pollKeyEvent(ctx, refresh)
+	if err != nil {
+		return "", err
+	}
+	if raw, ok := rawBytes(ev); ok {
+		return raw, nil
+	}
+	return approxRawChar(ev), nil
+}
+
+// Input read from a RawScreen which hasn't been decoded into events yet, and
+// the last event decoded along with the bytes it came from.
+var rawInput struct {
+	sync.Mutex
+	buf     []byte
+	lastEv  termbox.Event
+	lastRaw string
+	// Whether a bracketed paste has started and not yet ended.
+	pasting bool
+	// While buf starts with an unfinished escape sequence, the timer
+	// which gives up waiting for the rest of it, and whether it has.
+	// escGen tells the current timer from ones already stopped.
+	escTimer   *time.Timer
+	escGen     int
+	escExpired bool
+}
+
+// How long to wait for the rest of an escape sequence split across reads
+// before decoding what there is. Sequences are split by macOS terminals (see
+// termbox-go issue #132, which is why termbox waits 100ms there too), and by
+// screenPoll reading what termbox has buffered a chunk at a time. Elsewhere
+// the wait is shorter, so an ESC pressed on its own isn't held up long.
+var escWaitDelay = func() time.Duration {
+	if runtime.GOOS == "darwin" {
+		return 100 * time.Millisecond
+	}
+	return 25 * time.Millisecond
+}()
+
+// Returns the function pollEvent reads s with. RawScreens are read raw and
+// decoded here rather than by termbox, so that keys termbox doesn't know, such
+// as arrows with Shift or Ctrl held, can be told apart.
+func screenPoll(s Screen) func() termbox.Event {
+	rs, ok := s.(RawScreen)
+	if !ok {
+		return s.PollEvent
+	}
+	return func() termbox.Event {
+		for {
+			if ev, ok := decodeRawInput(rs); ok {
+				return ev
+			}
+			data := make([]byte, 64)
+			ev := rs.PollRawEvent(data)
+			if ev.Type == termbox.EventInterrupt && escWaitExpired() {
+				// Probably the escape timer's interrupt; decode what
+				// there is. If it was Post's, pollEvent takes what
+				// was posted before polling again.
+				continue
+			}
+			if ev.Type != termbox.EventRaw {
+				return ev
+			}
+			rawInput.Lock()
+			rawInput.buf = append(rawInput.buf, data[:ev.N]...)
+			rawInput.Unlock()
+		}
+	}
+}
+
+// Takes the next event from the raw input, if there's a whole one.
+func decodeRawInput(rs RawScreen) (termbox.Event, bool) {
+	rawInput.Lock()
+	defer rawInput.Unlock()
+	for len(rawInput.buf) > 0 {
+		ev, n, more := decodePasteMarker(rawInput.buf)
+		if more && rawInput.pasting {
+			// The terminal always sends the whole end marker, so
+			// wait however long it takes rather than ending up in
+			// the paste for good.
+			return termbox.Event{}, false
+		}
+		if n == 0 && !rawInput.escExpired && unfinishedEscape(rawInput.buf) {
+			startEscWait(rs)
+			return termbox.Event{}, false
+		}
+		if n > 0 {
+			rawInput.pasting = ev.Key == KeyPasteStart
+		}
+		if n == 0 {
+			ev, n = decodeModifiedKey(rawInput.buf)
+		}
+		if n == 0 {
+			ev = rs.ParseEvent(rawInput.buf)
+			n = ev.N
+		}
+		if n == 0 {
+			// Part of a UTF-8 sequence; wait for the rest.
+			return termbox.Event{}, false
+		}
+		raw := string(rawInput.buf[:n])
+		rawInput.buf = rawInput.buf[n:]
+		stopEscWait()
+		if ev.Type == termbox.EventNone {
+			continue
+		}
+		rawInput.lastEv, rawInput.lastRaw = ev, raw
+		return ev, true
+	}
+	return termbox.Event{}, false
+}
+
+// Reports whether buf could be the start of an escape sequence which hasn't
+// all been read yet: an ESC on its own, or a CSI or SS3 sequence without its
+// final byte, or a mouse report without its three bytes of data.
+func unfinishedEscape(buf []byte) bool {
+	if buf[0] != '\x1b' {
+		return false
+	}
+	if len(buf) == 1 {
+		return true
+	}
+	if buf[1] != '[' && buf[1] != 'O' {
+		return false
+	}
+	if strings.HasPrefix(string(buf), "\x1b[M") {
+		return len(buf) < 6
+	}
+	for _, c := range buf[2:] {
+		// Parameter and intermediate bytes; anything else ends it.
+		if c < 0x20 || c > 0x3f {
+			return false
+		}
+	}
+	return true
+}
+
+// Starts the timer which gives up waiting for the rest of an escape
+// sequence, interrupting rs's poll, unless it's already running. rawInput
+// must be locked.
+func startEscWait(rs RawScreen) {
+	if rawInput.escTimer != nil {
+		return
+	}
+	rawInput.escGen++
+	gen := rawInput.escGen
+	rawInput.escTimer = time.AfterFunc(escWaitDelay, func() {
+		rawInput.Lock()
+		current := rawInput.escGen == gen && rawInput.escTimer != nil
+		if current {
+			rawInput.escExpired = true
+		}
+		rawInput.Unlock()
+		if current {
+			rs.Interrupt()
+		}
+	})
+}
+
+// Stops waiting for the rest of an escape sequence. rawInput must be locked.
+func stopEscWait() {
+	if rawInput.escTimer != nil {
+		rawInput.escTimer.Stop()
+		rawInput.escTimer = nil
+	}
+	rawInput.escExpired = false
+}
+
+func escWaitExpired() bool {
+	rawInput.Lock()
+	defer rawInput.Unlock()
+	return rawInput.escExpired
+}
+
+// Returns the bytes ev was decoded from, if it was the last event decoded.
+func rawBytes(ev termbox.Event) (string, bool) {
+	rawInput.Lock()
+	defer rawInput.Unlock()
+	if rawInput.lastRaw == "" || ev != rawInput.lastEv {
+		return "", false
+	}
+	return rawInput.lastRaw, true
+}
+
+// What the terminal sends before and after pasted text in bracketed paste
+// mode.
+const (
+	pasteStartSeq = "\x1b[200~"
+	pasteEndSeq   = "\x1b[201~"
+)
+
+// SetBracketedPaste turns the terminal's bracketed paste mode on or off.
+func (TermboxScreen) SetBracketedPaste(on bool) {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	defer tty.Close()
+	if on {
+		tty.WriteString("\x1b[?2004h")
+	} else {
+		tty.WriteString("\x1b[?2004l")
+	}
+}
+
+// Decodes a bracketed paste marker at the start of buf into KeyPasteStart or
+// KeyPasteEnd, returning the number of bytes used. more is true if buf is
+// the start of a marker which hasn't all been read yet.
+func decodePasteMarker(buf []byte) (ev termbox.Event, n int, more bool) {
+	ev = termbox.Event{Type: termbox.EventKey}
+	for _, m := range []struct {
+		seq string
+		key termbox.Key
+	}{{pasteStartSeq, KeyPasteStart}, {pasteEndSeq, KeyPasteEnd}} {
+		if strings.HasPrefix(string(buf), m.seq) {
+			ev.Key = m.key
+			return ev, len(m.seq), false
+		}
+		if strings.HasPrefix(m.seq, string(buf)) {
+			return ev, 0, true
+		}
+	}
+	return ev, 0, false
+}
+
+// Keys sent by xterm as CSI 1 ; m <final>, and as CSI n ; m ~.
+var (
+	csiFinalKeys = map[byte]termbox.Key{
+		'A': termbox.KeyArrowUp,
+		'B': termbox.KeyArrowDown,
+		'C': termbox.KeyArrowRight,
+		'D': termbox.KeyArrowLeft,
+		'H': termbox.KeyHome,
+		'F': termbox.KeyEnd,
+		'P': termbox.KeyF1,
+		'Q': termbox.KeyF2,
+		'R': termbox.KeyF3,
+		'S': termbox.KeyF4,
+	}
+	csiTildeKeys = map[int]termbox.Key{
+		1:  termbox.KeyHome,
+		2:  termbox.KeyInsert,
+		3:  termbox.KeyDelete,
+		4:  termbox.KeyEnd,
+		5:  termbox.KeyPgup,
+		6:  termbox.KeyPgdn,
+		7:  termbox.KeyHome,
+		8:  termbox.KeyEnd,
+		11: termbox.KeyF1,
+		12: termbox.KeyF2,
+		13: termbox.KeyF3,
+		14: termbox.KeyF4,
+		15: termbox.KeyF5,
+		17: termbox.KeyF6,
+		18: termbox.KeyF7,
+		19: termbox.KeyF8,
+		20: termbox.KeyF9,
+		21: termbox.KeyF10,
+		23: termbox.KeyF11,
+		24: termbox.KeyF12,
+	}
+)
+
+// Decodes an xterm escape sequence for a navigation or function key pressed
+// with modifiers, e.g. "\x1b[1;2A" for S-UP, which termbox doesn't know.
+// Returns the number of bytes used, or 0 if buf doesn't start with one.
+func decodeModifiedKey(buf []byte) (termbox.Event, int) {
+	ev := termbox.Event{Type: termbox.EventKey}
+	if len(buf) < 6 || buf[0] != '\x1b' || buf[1] != '[' {
+		return ev, 0
+	}
+	var params [2]int
+	i, p := 2, 0
+	for ; i < len(buf); i++ {
+		c := buf[i]
+		if '0' <= c && c <= '9' {
+			params[p] = params[p]*10 + int(c-'0')
+		} else if c == ';' && p == 0 {
+			p++
+		} else {
+			break
+		}
+	}
+	if i == len(buf) || p != 1 || params[1] < 2 {
+		return ev, 0
+	}
+	var ok bool
+	if buf[i] == '~' {
+		ev.Key, ok = csiTildeKeys[params[0]]
+	} else if params[0] == 1 {
+		ev.Key, ok = csiFinalKeys[buf[i]]
+	}
+	if !ok {
+		return ev, 0
+	}
+	mods := params[1] - 1
+	if mods&1 != 0 {
+		ev.Mod |= ModShift
+	}
+	if mods&(2|8) != 0 {
+		ev.Mod |= termbox.ModAlt
+	}
+	if mods&4 != 0 {
+		ev.Mod |= ModCtrl
+	}
+	return ev, i + 1
 }
 
 //Parses a termbox.EventKey event and returns it as an emacs-ish keybinding string
 //(e.g. "C-c", "LEFT", "TAB", etc.)
 func ParseTermboxEvent(ev termbox.Event) string {
+	if ev.Type == termbox.EventMouse {
+		return mouseName(ev)
+	}
 	if ev.Ch == 0 {
-		prefix := ""
-		if ev.Mod == termbox.ModAlt {
-			prefix = "M-"
-		}
+		prefix := modifierPrefix(ev.Mod)
 		switch ev.Key {
 		case termbox.KeyBackspace2:
 			return prefix + "DEL"
@@ -62,6 +372,10 @@ func ParseTermboxEvent(ev termbox.Event) string {
 			return prefix + "insert"
 		case termbox.KeyEsc:
 			return prefix + "ESC"
+		case KeyPasteStart:
+			return prefix + "paste-start"
+		case KeyPasteEnd:
+			return prefix + "paste-end"
 		case termbox.KeyCtrlUnderscore:
 			if ev.Mod == termbox.ModAlt {
 				return "C-M-_"
@@ -79,6 +393,12 @@ func ParseTermboxEvent(ev termbox.Event) string {
 				return "M-SPC"
 			}
 			return " "
+		case termbox.KeyCtrlBackslash, termbox.KeyCtrlRsqBracket, termbox.KeyCtrl6:
+			if ev.Mod == termbox.ModAlt {
+				return fmt.Sprintf("C-M-%c", 64+ev.Key)
+			} else {
+				return fmt.Sprintf("C-%c", 64+ev.Key)
+			}
 		}
 		if ev.Key <= 0x1A {
 			if ev.Mod == termbox.ModAlt {
@@ -87,11 +407,7 @@ func ParseTermboxEvent(ev termbox.Event) string {
 				return fmt.Sprintf("C-%c", 96+ev.Key)
 			}
 		} else if ev.Key <= termbox.KeyF1 && ev.Key >= termbox.KeyF12 {
-			if ev.Mod == termbox.ModAlt {
-				return fmt.Sprintf("M-f%d", 1+(termbox.KeyF1-ev.Key))
-			} else {
-				return fmt.Sprintf("f%d", 1+(termbox.KeyF1-ev.Key))
-			}
+			return fmt.Sprintf("%sf%d", prefix, 1+(termbox.KeyF1-ev.Key))
 		}
 	} else if ev.Mod == termbox.ModAlt {
 		return fmt.Sprintf("M-%c", ev.Ch)
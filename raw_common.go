@@ -0,0 +1,55 @@
+package termutil
+
+import (
+	"context"
+
+	"github.com/nsf/termbox-go"
+)
+
+// RawScreen is a Screen that can also hand back the raw bytes of each input
+// event. GetRawChar uses it when the current Screen provides it, and otherwise
+// approximates the raw character from the parsed event.
+type RawScreen interface {
+	Screen
+	PollRawEvent(data []byte) termbox.Event
+	ParseEvent(data []byte) termbox.Event
+}
+
+// GetRawChar gets a raw character from the user, refreshing the screen if
+// it's resized.
+func GetRawChar(refresh func(int, int)) string {
+	ret, _ := GetRawCharContext(context.Background(), refresh)
+	return ret
+}
+
+// Returns a rough approximation of the raw character for a key event.
+// It ain't big, it ain't clever, but it should work well enough for backends
+// which can't give us the real bytes.
+func approxRawChar(ev termbox.Event) string {
+	prefix := ""
+	if ev.Mod == termbox.ModAlt {
+		prefix = "\x1b"
+	}
+	if ev.Ch == 0 {
+		if ev.Key <= 0x1F {
+			return prefix + string(rune(ev.Key))
+		}
+		switch ev.Key {
+		case termbox.KeyBackspace2, termbox.KeyBackspace, termbox.KeyDelete:
+			return prefix + "\x7f"
+		case termbox.KeyTab:
+			return prefix + "\x09"
+		case termbox.KeyEnter:
+			return prefix + "\x0d"
+		case termbox.KeyEsc:
+			return prefix + "\x1b"
+		case termbox.KeyCtrlUnderscore:
+			return prefix + "\x1f"
+		case termbox.KeyCtrlSpace:
+			return prefix + "\x00"
+		case termbox.KeySpace:
+			return prefix + " "
+		}
+	}
+	return prefix + string(ev.Ch)
+}
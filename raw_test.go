@@ -0,0 +1,245 @@
+// +build !windows
+
+package termutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// A RawScreen reading its input from chunks of bytes sent on a channel, as a
+// terminal's input arrives in reads.
+type chunkScreen struct {
+	*MemScreen
+	chunks    chan string
+	interrupt chan struct{}
+	pending   string
+}
+
+func newChunkScreen(t *testing.T) *chunkScreen {
+	t.Helper()
+	s := &chunkScreen{
+		MemScreen: NewMemScreen(40, 5),
+		chunks:    make(chan string, 16),
+		interrupt: make(chan struct{}),
+	}
+	SetScreen(s)
+	t.Cleanup(func() {
+		SetScreen(nil)
+		rawInput.Lock()
+		rawInput.buf = nil
+		rawInput.pasting = false
+		stopEscWait()
+		rawInput.Unlock()
+	})
+	return s
+}
+
+func (s *chunkScreen) PollRawEvent(data []byte) termbox.Event {
+	if s.pending == "" {
+		select {
+		case s.pending = <-s.chunks:
+		case <-s.interrupt:
+			return termbox.Event{Type: termbox.EventInterrupt}
+		}
+	}
+	n := copy(data, s.pending)
+	s.pending = s.pending[n:]
+	return termbox.Event{Type: termbox.EventRaw, N: n}
+}
+
+func (s *chunkScreen) ParseEvent(data []byte) termbox.Event {
+	return termbox.ParseEvent(data)
+}
+
+func (s *chunkScreen) Interrupt() {
+	s.interrupt <- struct{}{}
+}
+
+// Reads the next key, failing the test if it takes more than a second.
+func nextKey(t *testing.T) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ev, err := NextEvent(ctx)
+	if err != nil {
+		t.Fatalf("no key: %v", err)
+	}
+	return ParseTermboxEvent(ev)
+}
+
+func TestDecodeModifiedKey(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+		n    int
+	}{
+		{"\x1b[1;2A", "S-UP", 6},
+		{"\x1b[1;5C", "C-RIGHT", 6},
+		{"\x1b[1;3D", "M-LEFT", 6},
+		{"\x1b[1;8H", "C-M-S-Home", 6},
+		{"\x1b[1;6Pxyz", "C-S-f1", 6},
+		{"\x1b[15;2~", "S-f5", 7},
+		{"\x1b[24;5~", "C-f12", 7},
+		{"\x1b[5;3~", "M-prior", 6},
+		{"\x1b[3;5~a", "C-deletechar", 6},
+		// Not for us: unmodified, unknown or unfinished.
+		{"\x1b[A", "", 0},
+		{"\x1b[1;1A", "", 0},
+		{"\x1b[16;2~", "", 0},
+		{"\x1b[2;2Z", "", 0},
+		{"\x1b[1;2", "", 0},
+		{"\x1b[15;2", "", 0},
+		{"abcdefg", "", 0},
+	} {
+		ev, n := decodeModifiedKey([]byte(tc.in))
+		if n != tc.n {
+			t.Errorf("%q: used %d bytes, want %d", tc.in, n, tc.n)
+			continue
+		}
+		if n > 0 && ParseTermboxEvent(ev) != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.in, ParseTermboxEvent(ev), tc.want)
+		}
+	}
+}
+
+func TestDecodePasteMarker(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		key  termbox.Key
+		n    int
+		more bool
+	}{
+		{"\x1b[200~", KeyPasteStart, 6, false},
+		{"\x1b[201~hello", KeyPasteEnd, 6, false},
+		{"\x1b[20", 0, 0, true},
+		{"\x1b[201", 0, 0, true},
+		{"\x1b[2", 0, 0, true},
+		{"\x1b[", 0, 0, true},
+		{"\x1b", 0, 0, true},
+		{"\x1b[20~", 0, 0, false},
+		{"\x1b[202~", 0, 0, false},
+		{"hello", 0, 0, false},
+	} {
+		ev, n, more := decodePasteMarker([]byte(tc.in))
+		if n != tc.n || more != tc.more || (n > 0 && ev.Key != tc.key) {
+			t.Errorf("%q: got %v, %d, %v; want %v, %d, %v", tc.in, ev.Key, n, more, tc.key, tc.n, tc.more)
+		}
+	}
+}
+
+func TestDecodeRawInputAcrossReads(t *testing.T) {
+	s := newChunkScreen(t)
+	for _, chunk := range []string{"\x1b[1;2Aa", "\xe6\x97", "\xa5\x01", "\x1b[200", "~x\x1b[201~", "\x1b[15;2~"} {
+		s.chunks <- chunk
+	}
+	for _, want := range []string{"S-UP", "a", "日", "C-a", "paste-start", "x", "paste-end", "S-f5"} {
+		if got := nextKey(t); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestEscWait(t *testing.T) {
+	defer func(d time.Duration) { escWaitDelay = d }(escWaitDelay)
+	escWaitDelay = 50 * time.Millisecond
+	s := newChunkScreen(t)
+
+	// The rest of a sequence arriving in time is decoded with it.
+	s.chunks <- "\x1b[1;"
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.chunks <- "5C"
+	}()
+	if got := nextKey(t); got != "C-RIGHT" {
+		t.Fatalf("got %q, want C-RIGHT", got)
+	}
+
+	// An ESC on its own is decoded once the wait is up.
+	start := time.Now()
+	s.chunks <- "\x1b"
+	if got := nextKey(t); got != "ESC" {
+		t.Fatalf("got %q, want ESC", got)
+	}
+	if waited := time.Since(start); waited < escWaitDelay {
+		t.Errorf("ESC decoded after %v, before the wait was up", waited)
+	}
+	s.chunks <- "x"
+	if got := nextKey(t); got != "x" {
+		t.Fatalf("got %q, want x", got)
+	}
+
+	// Something posted while waiting is still delivered.
+	s.chunks <- "\x1b[1;"
+	ran := make(chan struct{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		Post(func() { close(ran) })
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var keys []string
+	for len(keys) < 4 {
+		ev, err := NextEvent(ctx)
+		if err != nil {
+			t.Fatalf("got %q before %v", keys, err)
+		}
+		if ev.Type == termbox.EventKey {
+			keys = append(keys, ParseTermboxEvent(ev))
+		}
+	}
+	select {
+	case <-ran:
+	default:
+		t.Error("posted function didn't run")
+	}
+	if keys[0] != "ESC" || keys[1] != "[" || keys[2] != "1" || keys[3] != ";" {
+		t.Errorf("got %q for an unfinished sequence", keys)
+	}
+}
+
+func TestDecodeRawInputAcrossChunks(t *testing.T) {
+	s := newChunkScreen(t)
+	// The sequence straddles the 64 bytes screenPoll reads at a time.
+	s.chunks <- strings.Repeat("a", 62) + "\x1b[1;5C"
+	for i := 0; i < 62; i++ {
+		if got := nextKey(t); got != "a" {
+			t.Fatalf("key %d: got %q, want a", i, got)
+		}
+	}
+	if got := nextKey(t); got != "C-RIGHT" {
+		t.Fatalf("got %q, want C-RIGHT", got)
+	}
+}
+
+func TestPasteEndSplit(t *testing.T) {
+	defer func(d time.Duration) { escWaitDelay = d }(escWaitDelay)
+	escWaitDelay = 5 * time.Millisecond
+	for i := 1; i < len(pasteEndSeq); i++ {
+		s := newChunkScreen(t)
+		s.chunks <- pasteStartSeq + "abc" + pasteEndSeq[:i]
+		go func(rest string) {
+			// Long past the escape wait.
+			time.Sleep(20 * time.Millisecond)
+			s.chunks <- rest
+		}(pasteEndSeq[i:] + "\r")
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		got, err := EditWithOptions("Paste", nil, EditOptions{Context: ctx})
+		cancel()
+		if got != "abc" || err != nil {
+			t.Errorf("end marker split after %d bytes: got %q, %v", i, got, err)
+		}
+	}
+	// A paste whose end marker straddles the 64 bytes read at a time.
+	s := newChunkScreen(t)
+	s.chunks <- pasteStartSeq + strings.Repeat("a", 56) + pasteEndSeq + "\r"
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if got, err := EditWithOptions("Paste", nil, EditOptions{Context: ctx}); got != strings.Repeat("a", 56) || err != nil {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
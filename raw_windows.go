@@ -1,61 +1,36 @@
 package termutil
 
 import (
+	"context"
 	"fmt"
 	"github.com/nsf/termbox-go"
 )
 
-// Returns a rough approximation of the raw character the user presses.
-// It ain't big, it ain't clever, but it should work well enough and get the
-// library compiling on Windows.
-func GetRawChar(refresh func(int, int)) string {
-	ev := func() termbox.Event {
-		for {
-			ret := termbox.PollEvent()
-			if ret.Type == termbox.EventKey {
-				return ret
-			} else if ret.Type == termbox.EventResize && refresh != nil {
-				refresh(ret.Width, ret.Height)
-			}
-		}
-	}()
-	prefix := ""
-	if ev.Mod == termbox.ModAlt {
-		prefix = "\x1b"
+// GetRawCharContext is GetRawChar, giving up with ctx.Err() if ctx is done
+// first. Windows termbox can't give us the raw bytes, so this is always a
+// rough approximation built from the parsed event.
+func GetRawCharContext(ctx context.Context, refresh func(int, int)) (string, error) {
+	ev, err := pollKeyEvent(ctx, refresh)
+	if err != nil {
+		return "", err
 	}
-	if ev.Ch == 0 {
-		if ev.Key <= 0x1F {
-			return prefix + string(ev.Key)
-		}
-		switch ev.Key {
-		case termbox.KeyBackspace2, termbox.KeyBackspace, termbox.KeyDelete:
-			return prefix + "\x7f"
-		case termbox.KeyTab:
-			return prefix + "\x09"
-		case termbox.KeyEnter:
-			return prefix + "\x0d"
-		case termbox.KeyEsc:
-			return prefix + "\x1b"
-		case termbox.KeyCtrlUnderscore:
-			return prefix + "\x1f"
-		case termbox.KeyCtrlSpace:
-			return prefix + "\x00"
-		case termbox.KeySpace:
-			return prefix + " "
-		}
-	}
-	return prefix + string(ev.Ch)
+	return approxRawChar(ev), nil
+}
+
+// Returns the function pollEvent reads s with.
+func screenPoll(s Screen) func() termbox.Event {
+	return s.PollEvent
 }
 
 //Parses a termbox.EventKey event and returns it as an emacs-ish keybinding string
 //(e.g. "C-c", "LEFT", "TAB", etc.)
 //The windows version interprets a C-h as a DEL
 func ParseTermboxEvent(ev termbox.Event) string {
+	if ev.Type == termbox.EventMouse {
+		return mouseName(ev)
+	}
 	if ev.Ch == 0 {
-		prefix := ""
-		if ev.Mod == termbox.ModAlt {
-			prefix = "M-"
-		}
+		prefix := modifierPrefix(ev.Mod)
 		switch ev.Key {
 		case termbox.KeyBackspace2, termbox.KeyBackspace:
 			return prefix + "DEL"
@@ -85,6 +60,10 @@ func ParseTermboxEvent(ev termbox.Event) string {
 			return prefix + "insert"
 		case termbox.KeyEsc:
 			return prefix + "ESC"
+		case KeyPasteStart:
+			return prefix + "paste-start"
+		case KeyPasteEnd:
+			return prefix + "paste-end"
 		case termbox.KeyCtrlUnderscore:
 			if ev.Mod == termbox.ModAlt {
 				return "C-M-_"
@@ -102,6 +81,12 @@ func ParseTermboxEvent(ev termbox.Event) string {
 				return "M-SPC"
 			}
 			return " "
+		case termbox.KeyCtrlBackslash, termbox.KeyCtrlRsqBracket, termbox.KeyCtrl6:
+			if ev.Mod == termbox.ModAlt {
+				return fmt.Sprintf("C-M-%c", 64+ev.Key)
+			} else {
+				return fmt.Sprintf("C-%c", 64+ev.Key)
+			}
 		}
 		if ev.Key <= 0x1A {
 			if ev.Mod == termbox.ModAlt {
@@ -110,11 +95,7 @@ func ParseTermboxEvent(ev termbox.Event) string {
 				return fmt.Sprintf("C-%c", 96+ev.Key)
 			}
 		} else if ev.Key <= termbox.KeyF1 && ev.Key >= termbox.KeyF12 {
-			if ev.Mod == termbox.ModAlt {
-				return fmt.Sprintf("M-f%d", 1+(termbox.KeyF1-ev.Key))
-			} else {
-				return fmt.Sprintf("f%d", 1+(termbox.KeyF1-ev.Key))
-			}
+			return fmt.Sprintf("%sf%d", prefix, 1+(termbox.KeyF1-ev.Key))
 		}
 	} else if ev.Mod == termbox.ModAlt {
 		return fmt.Sprintf("M-%c", ev.Ch)
@@ -0,0 +1,117 @@
+package termutil
+
+import (
+	"github.com/nsf/termbox-go"
+)
+
+// Screen is the backend every widget in this package draws to and reads its
+// events from. By default this is the terminal driven by termbox-go; programs
+// which own their own screen can install another one with SetScreen.
+type Screen interface {
+	SetCell(x, y int, ch rune, fg, bg termbox.Attribute)
+	Size() (int, int)
+	Clear(fg, bg termbox.Attribute) error
+	Flush() error
+	SetCursor(x, y int)
+	HideCursor()
+	PollEvent() termbox.Event
+	// Interrupt makes a PollEvent in progress return EventInterrupt. It
+	// may block until there is one to interrupt.
+	Interrupt()
+}
+
+// TermboxScreen is the default Screen, a thin adapter over termbox-go's
+// package-level functions. The caller is still responsible for calling
+// termbox.Init and termbox.Close.
+type TermboxScreen struct{}
+
+func (TermboxScreen) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	termbox.SetCell(x, y, ch, fg, bg)
+}
+
+func (TermboxScreen) Size() (int, int) {
+	return termbox.Size()
+}
+
+func (TermboxScreen) Clear(fg, bg termbox.Attribute) error {
+	return termbox.Clear(fg, bg)
+}
+
+func (TermboxScreen) Flush() error {
+	return termbox.Flush()
+}
+
+func (TermboxScreen) SetCursor(x, y int) {
+	termbox.SetCursor(x, y)
+}
+
+func (TermboxScreen) HideCursor() {
+	termbox.HideCursor()
+}
+
+func (TermboxScreen) PollEvent() termbox.Event {
+	return termbox.PollEvent()
+}
+
+func (TermboxScreen) Interrupt() {
+	termbox.Interrupt()
+}
+
+// SetMouse turns termbox's mouse input on or off.
+func (TermboxScreen) SetMouse(on bool) {
+	mode := termbox.SetInputMode(termbox.InputCurrent)
+	if on {
+		mode |= termbox.InputMouse
+	} else {
+		mode &^= termbox.InputMouse
+	}
+	termbox.SetInputMode(mode)
+}
+
+// MouseScreen is a Screen whose mouse input can be turned on and off, as
+// EnableMouse does.
+type MouseScreen interface {
+	Screen
+	SetMouse(on bool)
+}
+
+// EnableMouse turns mouse input on or off, if the current Screen supports
+// it. With it on, the widgets can be clicked and scrolled with the wheel.
+func EnableMouse(on bool) {
+	if ms, ok := screen.(MouseScreen); ok {
+		ms.SetMouse(on)
+	}
+}
+
+// PasteScreen is a Screen which can turn the terminal's bracketed paste mode
+// on and off, as EnableBracketedPaste does.
+type PasteScreen interface {
+	Screen
+	SetBracketedPaste(on bool)
+}
+
+// EnableBracketedPaste turns bracketed paste mode on or off, if the current
+// Screen supports it. With it on, text pasted into a prompt is inserted in
+// one go rather than typed key by key; see EditOptions.PasteNewlines. Turn it
+// off again before closing termbox.
+func EnableBracketedPaste(on bool) {
+	if ps, ok := screen.(PasteScreen); ok {
+		ps.SetBracketedPaste(on)
+	}
+}
+
+var screen Screen = TermboxScreen{}
+
+// SetScreen makes s the Screen used by every function in this package.
+// Passing nil restores the termbox default.
+func SetScreen(s Screen) {
+	if s == nil {
+		s = TermboxScreen{}
+	}
+	screen = s
+}
+
+// CurrentScreen returns the Screen the widgets are currently using.
+func CurrentScreen() Screen {
+	return screen
+}
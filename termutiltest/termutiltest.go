@@ -0,0 +1,64 @@
+// Package termutiltest provides golden file helpers for testing programs
+// which draw with termutil, by comparing MemScreen snapshots with files
+// under testdata.
+package termutiltest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	termutil "github.com/japanoise/termbox-util"
+)
+
+// UpdateEnv is the environment variable which, when set to anything but the
+// empty string, makes CheckGolden write its files instead of comparing.
+const UpdateEnv = "TERMUTIL_UPDATE_GOLDEN"
+
+// CheckGolden compares got with testdata/<name>.golden, relative to the
+// package under test, and reports a mismatch through t. If the environment
+// variable named by UpdateEnv is set, the file is written instead.
+func CheckGolden(t testing.TB, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("%v", err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (set %s=1 to create it)", err, UpdateEnv)
+		return
+	}
+	if string(want) == got {
+		return
+	}
+	gotLines := strings.Split(got, "\n")
+	wantLines := strings.Split(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			t.Errorf("%s differs at line %d:\n got: %q\nwant: %q\n\nfull output:\n%s", path, i+1, g, w, got)
+			return
+		}
+	}
+}
+
+// CheckGoldenScreen is CheckGolden applied to s.Snapshot().
+func CheckGoldenScreen(t testing.TB, name string, s *termutil.MemScreen) {
+	t.Helper()
+	CheckGolden(t, name, s.Snapshot())
+}
@@ -0,0 +1,218 @@
+package termutil
+
+import (
+	"context"
+	"unicode/utf8"
+)
+
+// TreeNode is a node of the tree ChoiceTree chooses from. Its Choice is how
+// the node is shown.
+type TreeNode struct {
+	Choice
+	Children []TreeNode
+	// HasChildren marks a node whose children are fetched by ChoiceTree's
+	// load function when the node is first expanded, instead of being
+	// listed in Children.
+	HasChildren bool
+	// Expanded nodes start off with their children shown.
+	Expanded bool
+}
+
+// ChoiceTreeKeymap holds the keys ChoiceTree adds to ChoiceKeymap for
+// expanding and collapsing nodes; see DefaultChoiceTreeKeymap.
+var ChoiceTreeKeymap = DefaultChoiceTreeKeymap()
+
+// DefaultChoiceTreeKeymap returns a new copy of the default keys for
+// expanding and collapsing nodes in ChoiceTree.
+func DefaultChoiceTreeKeymap() *Keymap {
+	return keymapFrom(map[string]string{
+		"RIGHT": "expand",
+		"LEFT":  "collapse",
+		"TAB":   "toggle-expand",
+	})
+}
+
+func choiceTree(ctx context.Context, title string, roots []TreeNode, load func([]int) []TreeNode) ([]int, error) {
+	t := &tree{load: load}
+	t.roots = t.makeNodes(roots, nil)
+	c := &chooser{title: title, choices: t.flatten(), tree: t}
+	c.filter()
+	ret, err := c.run(ctx, overlayKeymap(ChoiceKeymap, ChoiceTreeKeymap))
+	if err != nil {
+		return nil, err
+	}
+	if len(t.rows) == 0 {
+		return nil, nil
+	}
+	return t.rows[ret].node.path, nil
+}
+
+// A tree being chosen from. Its shown nodes are flattened into rows, which
+// are the choices of the chooser.
+type tree struct {
+	roots []*treeNode
+	load  func([]int) []TreeNode
+	rows  []treeRow
+}
+
+type treeNode struct {
+	TreeNode
+	path []int
+	// The nodes made from Children, or from what load returned, once the
+	// node has been expanded.
+	children []*treeNode
+	loaded   bool
+}
+
+type treeRow struct {
+	node *treeNode
+	// The row of the node's parent, or -1 for a root.
+	parent int
+	// The indentation guides and expansion marker drawn before the label.
+	prefix string
+}
+
+func (t *tree) makeNodes(nodes []TreeNode, parent []int) []*treeNode {
+	ret := make([]*treeNode, len(nodes))
+	for i, n := range nodes {
+		path := append(append([]int(nil), parent...), i)
+		ret[i] = &treeNode{TreeNode: n, path: path}
+	}
+	return ret
+}
+
+// Makes the node's children, calling load for them if need be.
+func (t *tree) loadChildren(n *treeNode) {
+	if n.loaded {
+		return
+	}
+	n.loaded = true
+	nodes := n.Children
+	if n.HasChildren && t.load != nil {
+		nodes = t.load(n.path)
+	}
+	n.children = t.makeNodes(nodes, n.path)
+}
+
+// Reports whether n has, or might have, children.
+func (n *treeNode) expandable() bool {
+	if n.loaded {
+		return len(n.children) > 0
+	}
+	return len(n.Children) > 0 || n.HasChildren
+}
+
+// Lays out the shown nodes as rows, and returns them as choices.
+func (t *tree) flatten() []Choice {
+	t.rows = t.rows[:0]
+	t.flattenNodes(t.roots, -1, "")
+	ret := make([]Choice, len(t.rows))
+	for i, row := range t.rows {
+		ret[i] = row.node.Choice
+	}
+	return ret
+}
+
+// Adds nodes as rows under the row parent. guides is the indentation drawn
+// for the levels above them.
+func (t *tree) flattenNodes(nodes []*treeNode, parent int, guides string) {
+	for i, n := range nodes {
+		last := i == len(nodes)-1
+		prefix, below := guides, guides
+		if parent >= 0 {
+			if last {
+				prefix += "└─"
+				below += "  "
+			} else {
+				prefix += "├─"
+				below += "│ "
+			}
+		}
+		if n.Expanded {
+			t.loadChildren(n)
+		}
+		switch {
+		case !n.expandable():
+			prefix += "  "
+		case n.Expanded:
+			prefix += "▾ "
+		default:
+			prefix += "▸ "
+		}
+		t.rows = append(t.rows, treeRow{node: n, parent: parent, prefix: prefix})
+		if n.Expanded {
+			t.flattenNodes(n.children, len(t.rows)-1, below)
+		}
+	}
+}
+
+// Adds to rows, which are in order, the rows of their ancestors.
+func (t *tree) withAncestors(rows []int) []int {
+	shown := make([]bool, len(t.rows))
+	for _, i := range rows {
+		for ; i >= 0 && !shown[i]; i = t.rows[i].parent {
+			shown[i] = true
+		}
+	}
+	ret := rows[:0]
+	for i, ok := range shown {
+		if ok {
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
+// Returns the label of row i with its prefix, and the positions of the runes
+// matching the query moved along to match.
+func (t *tree) indent(i int, label string, pos []int) (string, []int) {
+	prefix := t.rows[i].prefix
+	n := utf8.RuneCountInString(prefix)
+	moved := make([]int, len(pos))
+	for j, p := range pos {
+		moved[j] = p + n
+	}
+	return prefix + label, moved
+}
+
+// Expands or collapses the selected node. Expanding a node already expanded
+// moves to its first child, and collapsing one which isn't moves to its
+// parent.
+func (c *chooser) treeCommand(cmd string) {
+	i := c.rows[c.selection]
+	row := c.tree.rows[i]
+	n := row.node
+	switch {
+	case cmd == "expand" && n.Expanded:
+		if c.selection+1 < len(c.rows) && c.tree.rows[c.rows[c.selection+1]].parent == i {
+			c.selectRow(c.selection+1, 1)
+		}
+	case cmd == "collapse" && !n.Expanded:
+		if row.parent >= 0 {
+			c.selectNode(c.tree.rows[row.parent].node)
+		}
+	default:
+		if !n.Expanded {
+			c.tree.loadChildren(n)
+			if !n.expandable() {
+				return
+			}
+		}
+		n.Expanded = !n.Expanded
+		offset := c.offset
+		c.choices = c.tree.flatten()
+		c.filter()
+		c.offset = offset
+		c.selectNode(n)
+	}
+}
+
+// Selects the row showing n, if there is one.
+func (c *chooser) selectNode(n *treeNode) {
+	for j, i := range c.rows {
+		if c.tree.rows[i].node == n {
+			c.selectRow(j, -1)
+			return
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package termutil
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A tree whose docs node loads its children, noting the paths loaded.
+func testTree(loads *[][]int) ([]TreeNode, func([]int) []TreeNode) {
+	roots := []TreeNode{
+		{Choice: Choice{Label: "src"}, Children: []TreeNode{
+			{Choice: Choice{Label: "main.go"}},
+			{Choice: Choice{Label: "util"}, Children: []TreeNode{
+				{Choice: Choice{Label: "strings.go"}},
+			}},
+		}},
+		{Choice: Choice{Label: "docs"}, HasChildren: true},
+		{Choice: Choice{Label: "README"}},
+	}
+	load := func(path []int) []TreeNode {
+		*loads = append(*loads, path)
+		return []TreeNode{{Choice: Choice{Label: "guide.md"}}, {Choice: Choice{Label: "api.md"}}}
+	}
+	return roots, load
+}
+
+func TestChoiceTree(t *testing.T) {
+	s := memScreen(t, 40, 10)
+	for _, tc := range []struct {
+		name  string
+		keys  []string
+		want  []int
+		loads [][]int
+	}{
+		{"root", []string{"C-n", "C-n", "RET"}, []int{2}, nil},
+		{"RIGHT and LEFT", []string{"RIGHT", "RIGHT", "C-n", "RIGHT", "RIGHT", "LEFT", "LEFT", "RET"}, []int{0, 1}, nil},
+		{"LEFT to the parent", []string{"RIGHT", "RIGHT", "C-n", "LEFT", "RET"}, []int{0}, nil},
+		{"LEFT at a root", []string{"LEFT", "C-n", "RET"}, []int{1}, nil},
+		{"TAB", []string{"TAB", "C-n", "C-n", "TAB", "C-n", "RET"}, []int{0, 1, 0}, nil},
+		{"TAB on a leaf", []string{"TAB", "C-n", "TAB", "C-n", "RET"}, []int{0, 1}, nil},
+		{"collapsed", []string{"RIGHT", "LEFT", "C-n", "RET"}, []int{1}, nil},
+		{"loaded once", []string{"C-n", "RIGHT", "LEFT", "TAB", "RIGHT", "C-n", "RET"}, []int{1, 1}, [][]int{{1}}},
+		{"filtered", []string{"C-n", "RIGHT", "a", "p", "i", "C-n", "RET"}, []int{1, 1}, [][]int{{1}}},
+	} {
+		var loads [][]int
+		roots, load := testTree(&loads)
+		s.PushKeys(tc.keys...)
+		if got := ChoiceTree("Files", roots, load); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+		if !reflect.DeepEqual(loads, tc.loads) {
+			t.Errorf("%s: loaded %v, want %v", tc.name, loads, tc.loads)
+		}
+	}
+}
+
+func TestChoiceTreeFilterKeepsAncestors(t *testing.T) {
+	s := memScreen(t, 40, 10)
+	var loads [][]int
+	roots, load := testTree(&loads)
+	roots[0].Expanded = true
+	roots[0].Children[1].Expanded = true
+	s.PushKeys("s", "t", "r", "C-n", "C-n", "RET")
+	s.RecordFrames(true)
+	if got, want := ChoiceTree("Files", roots, load), []int{0, 1, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	frames := s.Frames()
+	frame := frames[len(frames)-1]
+	for _, label := range []string{"src", "util", "strings.go"} {
+		if !strings.Contains(frame, label) {
+			t.Errorf("%s not shown:\n%s", label, frame)
+		}
+	}
+	for _, label := range []string{"main.go", "docs", "README"} {
+		if strings.Contains(frame, label) {
+			t.Errorf("%s shown:\n%s", label, frame)
+		}
+	}
+}
+
+func TestChoiceTreeCancel(t *testing.T) {
+	s := memScreen(t, 40, 10)
+	var loads [][]int
+	roots, load := testTree(&loads)
+	s.PushKeys("C-n", "C-g")
+	if got, err := ChoiceTreeContext(context.Background(), "Files", roots, load); got != nil || err != ErrCancelled {
+		t.Errorf("got %v, %v; want nil, %v", got, err, ErrCancelled)
+	}
+}